@@ -3,18 +3,27 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
+	"fmt"
 	_ "go-chi-api/docs"
+	"go-chi-api/internal/config"
+	"go-chi-api/internal/devcert"
+	"go-chi-api/internal/logging"
+	"go-chi-api/internal/otel"
 	"go-chi-api/internal/server"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 )
 
 const (
-	serviceName    = "go-chi-api"
-	serviceVersion = "1.0"
+	serviceVersion      = "1.0"
+	configWatchInterval = 5 * time.Second
 )
 
 // @title        Go Chi API
@@ -25,23 +34,140 @@ const (
 // @host         localhost:3000
 // @BasePath     /
 func main() {
-	server, err := server.NewServer(context.Background(), serviceName, serviceVersion)
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "dev-cert" {
+		os.Exit(devCertCommand(os.Args[2:]))
+	}
+
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(logging.ParseLevel(cfg.Runtime.LogLevel))
+	logger := logging.New(os.Stdout, cfg.Logging.Format, logLevel, otel.NewLogHandler())
+	slog.SetDefault(logger)
+
+	store := config.NewStore(cfg, os.Args[1:])
+	store.OnReload(func(cfg *config.Config, _ error) {
+		logLevel.Set(logging.ParseLevel(cfg.Runtime.LogLevel))
+	})
+
+	server, err := server.NewServer(context.Background(), store, serviceVersion, server.WithLogger(logger))
 	if err != nil {
-		log.Fatalln(err)
+		logger.Error("Failed to create server", "error", err)
+		os.Exit(1)
 	}
 
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go store.Watch(watchCtx, configWatchInterval)
+
 	go func() {
 		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalln("Error:", err)
+			logger.Error("Server failed", "error", err)
+			os.Exit(1)
 		}
 	}()
 
 	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-	<-sig
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for s := range sig {
+		if s != syscall.SIGHUP {
+			break
+		}
+
+		_ = store.Reload()
+	}
 
 	if err := server.Shutdown(); err != nil {
-		log.Fatalln(err)
+		logger.Error("Failed to shut down cleanly", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Server shutdown")
+}
+
+// Handles `config <subcommand> [flags]`, returning the process exit code
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "Usage: api config print [flags]")
+		return 2
+	}
+
+	cfg, err := config.Load(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := cfg.Write(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// Handles `dev-cert [flags]`, writing a self-signed certificate and key for
+// serving HTTPS locally, and returning the process exit code
+func devCertCommand(args []string) int {
+	flags := flag.NewFlagSet("dev-cert", flag.ContinueOnError)
+	dir := flags.String("dir", ".", "Directory to write cert.pem and key.pem to")
+	hosts := flags.String("hosts", "localhost,127.0.0.1,::1", "Comma separated host names and IP addresses the certificate is for")
+	validFor := flags.Duration("valid-for", 30*24*time.Hour, "How long the certificate is valid for")
+	force := flags.Bool("force", false, "Overwrite existing files")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
 	}
-	log.Println("Server shutdown")
+
+	certPEM, keyPEM, err := devcert.Generate(strings.Split(*hosts, ","), time.Now(), *validFor)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// Refuse to replace a certificate in use unless asked to
+	openFlags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !*force {
+		openFlags |= os.O_EXCL
+	}
+
+	certFile := filepath.Join(*dir, "cert.pem")
+	keyFile := filepath.Join(*dir, "key.pem")
+	for _, file := range []struct {
+		path string
+		data []byte
+		perm os.FileMode
+	}{
+		{certFile, certPEM, 0o644},
+		{keyFile, keyPEM, 0o600},
+	} {
+		f, err := os.OpenFile(file.path, openFlags, file.perm)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		_, err = f.Write(file.data)
+		if err = errors.Join(err, f.Close()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	fmt.Printf("Wrote %s and %s. Serve them with TLS_CERT_FILE=%s TLS_KEY_FILE=%s\n", certFile, keyFile, certFile, keyFile)
+	return 0
 }
@@ -0,0 +1,40 @@
+// Writes the Grafana dashboards and Prometheus rules defined in
+// internal/dashboards to the o11y directory
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"go-chi-api/internal/dashboards"
+)
+
+func main() {
+	out := flag.String("out", "o11y", "directory to write the generated files to")
+	flag.Parse()
+
+	files, err := dashboards.Files()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		path := filepath.Join(*out, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		if err := os.WriteFile(path, files[name], 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Wrote", path)
+	}
+}
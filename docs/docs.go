@@ -23,10 +23,14 @@ const docTemplate = `{
             "get": {
                 "description": "Hello, there!",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
                 ],
                 "tags": [
                     "hello"
@@ -35,11 +39,53 @@ const docTemplate = `{
                 "responses": {}
             }
         },
+        "/v1/auth/csrf": {
+            "get": {
+                "description": "Issues a CSRF token for the current session, also setting it as a cookie. Requests that change state while logged in must send it in the X-CSRF-Token header. Fetch a new token after logging in",
+                "produces": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Get CSRF token",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.CsrfTokenResponse"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
         "/v1/auth/current": {
             "get": {
                 "description": "Gets the details of the authenticated user",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
                 ],
                 "tags": [
                     "auth"
@@ -50,10 +96,50 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/server.GetCurrentUserResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Entity tag of the representation, for If-None-Match"
+                            },
+                            "Last-Modified": {
+                                "type": "string",
+                                "description": "When the user was last changed, for If-Modified-Since"
+                            }
                         }
                     },
+                    "304": {
+                        "description": "Not Modified"
+                    },
                     "401": {
-                        "description": "Unauthorized"
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     }
                 }
             }
@@ -62,13 +148,26 @@ const docTemplate = `{
             "post": {
                 "description": "Log in user via username and password",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
                 ],
                 "tags": [
                     "auth"
                 ],
                 "summary": "Login user",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "CSRF token from /auth/csrf, required when the authentication cookie is sent",
+                        "name": "X-CSRF-Token",
+                        "in": "header"
+                    },
                     {
                         "description": "Login Request Body",
                         "name": "request",
@@ -83,11 +182,53 @@ const docTemplate = `{
                     "204": {
                         "description": "No Content"
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
                     "401": {
-                        "description": "Unauthorized"
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     },
                     "500": {
-                        "description": "Internal Server Error"
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     }
                 }
             }
@@ -96,13 +237,32 @@ const docTemplate = `{
             "post": {
                 "description": "Register user with the given username and password",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
                 ],
                 "tags": [
                     "auth"
                 ],
                 "summary": "Register user",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Replay the first response to retries with the same key",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "CSRF token from /auth/csrf, required when the authentication cookie is sent",
+                        "name": "X-CSRF-Token",
+                        "in": "header"
+                    },
                     {
                         "description": "Register Request Body",
                         "name": "request",
@@ -114,20 +274,79 @@ const docTemplate = `{
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "201": {
+                        "description": "Created",
+                        "headers": {
+                            "Idempotent-Replayed": {
+                                "type": "string",
+                                "description": "Set to true when the response is replayed for a retry"
+                            }
+                        }
                     },
-                    "401": {
-                        "description": "Unauthorized"
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
                     }
                 }
             }
         },
         "/v1/health": {
             "get": {
-                "description": "Determine health of the API",
+                "description": "Determine health of the API and each of its dependencies. A failing optional dependency degrades the API, while a failing required dependency makes it unhealthy. The details of each dependency are only reported by the admin listener",
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
                 ],
                 "tags": [
                     "health"
@@ -139,12 +358,184 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/server.HealthCheckResponse"
                         }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/server.HealthCheckResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/health/live": {
+            "get": {
+                "description": "Succeeds while the process is able to serve requests, regardless of its dependencies",
+                "produces": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.HealthCheckResponse"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/health/ready": {
+            "get": {
+                "description": "Succeeds while every required dependency is healthy. Fails once the server has begun shutting down, so load balancers stop routing to it",
+                "produces": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.HealthCheckResponse"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/server.HealthCheckResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/health/startup": {
+            "get": {
+                "description": "Fails until every required dependency has been healthy at least once, then always succeeds",
+                "produces": [
+                    "application/json",
+                    "application/cbor",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Startup probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/server.HealthCheckResponse"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/server.HealthCheckResponse"
+                        }
                     }
                 }
             }
         }
     },
     "definitions": {
+        "problem.FieldError": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "password must be at least 16 characters"
+                },
+                "pointer": {
+                    "description": "JSON pointer to the field within the request body",
+                    "type": "string",
+                    "example": "/password"
+                }
+            }
+        },
+        "problem.Problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "The request body failed validation"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/problem.FieldError"
+                    }
+                },
+                "instance": {
+                    "description": "The path of the request the problem occurred in",
+                    "type": "string",
+                    "example": "/v1.0/auth/register"
+                },
+                "request_id": {
+                    "description": "Identify the request in logs and traces",
+                    "type": "string",
+                    "example": "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Validation Failed"
+                },
+                "trace_id": {
+                    "type": "string",
+                    "example": "4bf92f3577b34da6a3ce929d0e0e4736"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "https://github.com/ryanseipp/go-chi-api/blob/main/docs/problems.md#validation-failed"
+                }
+            }
+        },
+        "server.CsrfTokenResponse": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string",
+                    "example": "8J2v0e8Qk3bX0yqZx7GgAq1cVbYQH1tq8wqU8tq3hJE.mF6ry8mE0vGJm4HqU5mV0q1cZ5l9mJpP3n8b6Yx1Hk4"
+                }
+            }
+        },
         "server.GetCurrentUserResponse": {
             "type": "object",
             "properties": {
@@ -167,6 +558,10 @@ const docTemplate = `{
         "server.HealthCheckInfo": {
             "type": "object",
             "properties": {
+                "data": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
                 "description": {
                     "type": "string",
                     "example": "Pinged DB"
@@ -179,6 +574,10 @@ const docTemplate = `{
                     "type": "string",
                     "example": "Database"
                 },
+                "required": {
+                    "type": "boolean",
+                    "example": true
+                },
                 "status": {
                     "type": "string",
                     "example": "Healthy"
@@ -7,16 +7,17 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"go-chi-api/internal/config"
 	"go-chi-api/internal/domain"
-	"log"
+	"go-chi-api/internal/logging"
+	"go-chi-api/internal/problem"
+	"log/slog"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	_ "github.com/joho/godotenv/autoload"
 	"golang.org/x/crypto/argon2"
 )
 
@@ -25,14 +26,10 @@ const (
 	ValidRehashNeeded
 	Invalid
 
-	argonMemory      uint32 = 12288
-	argonIterations  uint32 = 3
-	argonParallelism uint8  = 1
-	argonSaltLength  uint8  = 16
-	argonKeyLength   uint32 = 32
+	jwtIssuer string = "go-api"
 
-	jwtIssuer  string = "go-api"
-	cookieName string = "token"
+	// The cookie SetAuthenticationCookie stores the authentication token in
+	CookieName string = "token"
 
 	ContextValueUserId string = "userId"
 )
@@ -68,31 +65,49 @@ type (
 	}
 
 	service struct {
-		jwtSecret string
+		jwtSecret      string
+		cookieLifetime time.Duration
+		params         argon2idParams
+		metrics        *metrics
 	}
 )
 
 var (
 	ErrInvalidHash         = errors.New("Hash provided in an incorrect format")
 	ErrIncompatibleVersion = errors.New("Hash utilizes unsupported Argon2 algorithm or version")
+	ErrMissingJwtSecret    = errors.New("JWT secret was not configured")
 )
 
-func New() Service {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("Environment variable JWT_SECRET was not set")
+func New(cfg config.Authentication) (Service, error) {
+	if cfg.JwtSecret == "" {
+		return nil, ErrMissingJwtSecret
 	}
 
-	return &service{
-		jwtSecret,
+	metrics, err := newMetrics()
+	if err != nil {
+		return nil, err
 	}
+
+	return &service{
+		jwtSecret:      cfg.JwtSecret,
+		cookieLifetime: cfg.CookieLifetime,
+		params: argon2idParams{
+			memory:      cfg.Argon2.Memory,
+			iterations:  cfg.Argon2.Iterations,
+			parallelism: cfg.Argon2.Parallelism,
+			saltLength:  cfg.Argon2.SaltLength,
+			keyLength:   cfg.Argon2.KeyLength,
+		},
+		metrics: metrics,
+	}, nil
 }
 
 func (s *service) UseAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie(cookieName)
+		cookie, err := r.Cookie(CookieName)
 		if err != nil || cookie.Expires.After(time.Now()) {
-			w.WriteHeader(http.StatusUnauthorized)
+			s.metrics.recordTokenValidationFailure(r.Context(), tokenMissing)
+			problem.Write(w, r, problem.Unauthorized.New("Authentication is required"))
 			return
 		}
 
@@ -106,22 +121,34 @@ func (s *service) UseAuthentication(next http.Handler) http.Handler {
 		)
 
 		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
+			reason, detail := tokenMalformed, "The authentication token is invalid"
+			switch {
+			case errors.Is(err, jwt.ErrTokenExpired):
+				reason, detail = tokenExpired, "The authentication token has expired"
+			case errors.Is(err, jwt.ErrTokenSignatureInvalid):
+				reason = tokenInvalidSignature
+			}
+
+			s.metrics.recordTokenValidationFailure(r.Context(), reason)
+			problem.Write(w, r, problem.InvalidToken.New(detail))
 			return
 		}
 
 		claims, ok := token.Claims.(*appClaims)
 		if !ok || len(claims.Subject) == 0 {
-			w.WriteHeader(http.StatusUnauthorized)
+			s.metrics.recordTokenValidationFailure(r.Context(), tokenInvalidSubject)
+			problem.Write(w, r, problem.InvalidToken.New("The authentication token is invalid"))
 			return
 		}
 
 		id, err := strconv.ParseInt(claims.Subject, 10, 64)
 		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
+			s.metrics.recordTokenValidationFailure(r.Context(), tokenInvalidSubject)
+			problem.Write(w, r, problem.InvalidToken.New("The authentication token is invalid"))
 			return
 		}
 
+		logging.AddAttrs(r.Context(), slog.Int64("user_id", id))
 		ctx := context.WithValue(r.Context(), ContextValueUserId, id)
 		w.Header().Set("Cache-Control", "max-age=0,private,must-revalidate")
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -145,9 +172,9 @@ func (s *service) encodeHash(hash []byte, salt []byte) string {
 	return fmt.Sprintf(
 		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
 		argon2.Version,
-		argonMemory,
-		argonIterations,
-		argonParallelism,
+		s.params.memory,
+		s.params.iterations,
+		s.params.parallelism,
 		b64Salt,
 		b64Hash,
 	)
@@ -191,20 +218,22 @@ func (s *service) decodeHash(encodedHash string) (hash []byte, salt []byte, p *a
 }
 
 func (s *service) HashPassword(password string) (string, error) {
-	salt, err := s.generateRandomBytes(argonSaltLength)
+	salt, err := s.generateRandomBytes(s.params.saltLength)
 	if err != nil {
 		return "", err
 	}
 
+	start := time.Now()
 	var hash []byte
 	hash = argon2.IDKey(
 		[]byte(password),
 		salt,
-		argonIterations,
-		argonMemory,
-		argonParallelism,
-		argonKeyLength,
+		s.params.iterations,
+		s.params.memory,
+		s.params.parallelism,
+		s.params.keyLength,
 	)
+	s.metrics.recordHashDuration("hash", start)
 
 	encodedHash := s.encodeHash(hash, salt)
 
@@ -219,18 +248,20 @@ func (s *service) VerifyHashedPassword(password string, encoded *string) (HashVa
 	}
 
 	hash, salt, p, decodeErr := s.decodeHash(encodedPassword)
+	start := time.Now()
 	passwordHash := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, p.keyLength)
+	s.metrics.recordHashDuration("verify", start)
 	compareResult := subtle.ConstantTimeCompare(hash, passwordHash)
 
 	if compareResult == 0 || decodeErr != nil {
 		return Invalid, nil
 	}
 
-	if p.memory != argonMemory ||
-		p.iterations != argonIterations ||
-		p.parallelism != argonParallelism ||
-		p.keyLength != argonKeyLength ||
-		p.saltLength != argonSaltLength {
+	if p.memory != s.params.memory ||
+		p.iterations != s.params.iterations ||
+		p.parallelism != s.params.parallelism ||
+		p.keyLength != s.params.keyLength ||
+		p.saltLength != s.params.saltLength {
 		return ValidRehashNeeded, nil
 	}
 
@@ -239,7 +270,7 @@ func (s *service) VerifyHashedPassword(password string, encoded *string) (HashVa
 
 func (s *service) SetAuthenticationCookie(w http.ResponseWriter, user *domain.User) error {
 	now := time.Now()
-	expiresAt := now.UTC().Add(72 * time.Hour)
+	expiresAt := now.UTC().Add(s.cookieLifetime)
 
 	claims := appClaims{
 		user.Username,
@@ -259,7 +290,7 @@ func (s *service) SetAuthenticationCookie(w http.ResponseWriter, user *domain.Us
 	}
 
 	http.SetCookie(w, &http.Cookie{
-		Name:     cookieName,
+		Name:     CookieName,
 		Value:    tokenString,
 		Expires:  expiresAt,
 		Secure:   true,
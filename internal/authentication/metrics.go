@@ -0,0 +1,74 @@
+package authentication
+
+import (
+	"context"
+	"time"
+
+	telemetry "go-chi-api/internal/otel"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	apimetric "go.opentelemetry.io/otel/metric"
+)
+
+const instrumentationName = "go-chi-api/internal/authentication"
+
+// Reasons a request's token was rejected by UseAuthentication
+const (
+	tokenMissing          = "missing"
+	tokenExpired          = "expired"
+	tokenInvalidSignature = "invalid_signature"
+	tokenMalformed        = "malformed"
+	tokenInvalidSubject   = "invalid_subject"
+)
+
+type metrics struct {
+	tokenValidationFailures apimetric.Int64Counter
+	hashDuration            apimetric.Float64Histogram
+}
+
+// Argon2 is deliberately slow, so the buckets extend further than those of
+// the default histogram aggregation, in seconds
+var hashDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+func newMetrics() (*metrics, error) {
+	meter := otel.Meter(instrumentationName)
+
+	tokenValidationFailures, err := meter.Int64Counter(
+		telemetry.MetricAuthTokenValidationFailures,
+		apimetric.WithDescription("Requests rejected by authentication middleware, by reason"),
+		apimetric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	hashDuration, err := meter.Float64Histogram(
+		telemetry.MetricAuthPasswordHashDuration,
+		apimetric.WithDescription("Duration of Argon2 password hashing, by operation"),
+		apimetric.WithUnit("s"),
+		apimetric.WithExplicitBucketBoundaries(hashDurationBuckets...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metrics{
+		tokenValidationFailures: tokenValidationFailures,
+		hashDuration:            hashDuration,
+	}, nil
+}
+
+func (m *metrics) recordTokenValidationFailure(ctx context.Context, reason string) {
+	m.tokenValidationFailures.Add(ctx, 1, apimetric.WithAttributes(attribute.String("reason", reason)))
+}
+
+// Records the time since start taken to hash a password for operation, which
+// is either "hash" or "verify"
+func (m *metrics) recordHashDuration(operation string, start time.Time) {
+	m.hashDuration.Record(
+		context.Background(),
+		time.Since(start).Seconds(),
+		apimetric.WithAttributes(attribute.String("operation", operation)),
+	)
+}
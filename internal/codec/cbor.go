@@ -0,0 +1,111 @@
+package codec
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"go-chi-api/internal/problem"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Encodes and decodes CBOR, as defined by RFC 8949
+var CBOR Codec = newCborCodec()
+
+type cborCodec struct {
+	encode cbor.EncMode
+	decode cbor.DecMode
+
+	// Fails on unknown fields
+	strictDecode cbor.DecMode
+}
+
+func newCborCodec() cborCodec {
+	// Encoded the same way every time, so responses can be compared byte for byte
+	encode, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		panic(err)
+	}
+
+	options := cbor.DecOptions{DupMapKey: cbor.DupMapKeyEnforcedAPF}
+	decode, err := options.DecMode()
+	if err != nil {
+		panic(err)
+	}
+
+	options.ExtraReturnErrors = cbor.ExtraDecErrorUnknownField
+	strictDecode, err := options.DecMode()
+	if err != nil {
+		panic(err)
+	}
+
+	return cborCodec{encode: encode, decode: decode, strictDecode: strictDecode}
+}
+
+func (cborCodec) MediaTypes() []string {
+	return []string{"application/cbor"}
+}
+
+func (c cborCodec) Marshal(v any) ([]byte, error) {
+	return c.encode.Marshal(v)
+}
+
+func (c cborCodec) Decode(r io.Reader, v any, opts DecodeOptions) error {
+	mode := c.decode
+	if opts.DisallowUnknownFields {
+		mode = c.strictDecode
+	}
+
+	decoder := mode.NewDecoder(r)
+	err := decoder.Decode(v)
+	if err == nil {
+		var trailing cbor.RawMessage
+		if trailingErr := decoder.Decode(&trailing); !errors.Is(trailingErr, io.EOF) {
+			if trailingErr == nil || isContentError(trailingErr, "cbor: ") {
+				return &DecodeError{Detail: "The request body has unexpected data after the CBOR value", Err: trailingErr}
+			}
+			return trailingErr
+		}
+	}
+
+	if err == nil || !isContentError(err, "cbor: ") {
+		return err
+	}
+
+	return cborDecodeError(err)
+}
+
+func cborDecodeError(err error) *DecodeError {
+	var typeError *cbor.UnmarshalTypeError
+	var unknownFieldError *cbor.UnknownFieldError
+	var dupKeyError *cbor.DupMapKeyError
+	switch {
+	case errors.Is(err, io.EOF):
+		return &DecodeError{Detail: "The request body is empty", Err: err}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &DecodeError{Detail: "The request body ends before the CBOR value is complete", Err: err}
+	case errors.As(err, &typeError):
+		if typeError.StructFieldName == "" {
+			return &DecodeError{Detail: "The request body has a value of the wrong type: " + typeError.Error(), Err: err}
+		}
+
+		// Named by the struct's type followed by the field's key
+		field := typeError.StructFieldName[strings.LastIndex(typeError.StructFieldName, ".")+1:]
+		return &DecodeError{
+			Detail: "The request body has a field of the wrong type",
+			Field: &problem.FieldError{
+				Pointer: fieldPointer(field),
+				Detail:  field + " has the wrong type",
+			},
+			Err: err,
+		}
+	case errors.As(err, &unknownFieldError):
+		// The decoder reports unknown fields only by their index in the map
+		return &DecodeError{Detail: "The request body has an unknown field", Err: err}
+	case errors.As(err, &dupKeyError):
+		return &DecodeError{Detail: "The request body has a field more than once", Err: err}
+	}
+
+	return &DecodeError{Detail: "The request body is not valid CBOR: " + err.Error(), Err: err}
+}
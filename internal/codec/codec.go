@@ -0,0 +1,209 @@
+// Package codec encodes responses and decodes request bodies in the wire
+// formats the API supports, choosing between them by the Accept and
+// Content-Type headers. Every codec names fields by their JSON names, so the
+// same types serve each format
+package codec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go-chi-api/internal/problem"
+)
+
+type (
+	// Encodes and decodes values in one wire format
+	Codec interface {
+		// The media types of the format, preferred first. The first is used as
+		// the Content-Type of responses
+		MediaTypes() []string
+
+		Marshal(v any) ([]byte, error)
+
+		// Decodes a single value from r into v, failing if r holds anything
+		// more. Errors caused by the content of r are *DecodeError
+		Decode(r io.Reader, v any, opts DecodeOptions) error
+	}
+
+	DecodeOptions struct {
+		// Fail when r has fields that v does not
+		DisallowUnknownFields bool
+	}
+
+	// Describes why a body could not be decoded, and where, without echoing
+	// its contents
+	DecodeError struct {
+		Detail string
+
+		// The field at fault, if the error concerns one
+		Field *problem.FieldError
+
+		Err error
+	}
+)
+
+func (e *DecodeError) Error() string {
+	return e.Detail
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Chooses between codecs by the media types of requests
+type Registry struct {
+	codecs []Codec
+}
+
+// Creates a registry of codecs in order of the server's preference. The first
+// is used when a request has no preference
+func NewRegistry(codecs ...Codec) *Registry {
+	return &Registry{codecs: codecs}
+}
+
+// Returns the media type of each codec, for describing what is supported
+func (r *Registry) MediaTypes() []string {
+	mediaTypes := make([]string, len(r.codecs))
+	for i, c := range r.codecs {
+		mediaTypes[i] = c.MediaTypes()[0]
+	}
+
+	return mediaTypes
+}
+
+// Returns the codec for a Content-Type header. Media types with a structured
+// syntax suffix, such as application/merge-patch+json, are decoded by the
+// codec of the suffix. Any charset must be UTF-8
+func (r *Registry) ForContentType(contentType string) (Codec, bool) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+		return nil, false
+	}
+
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 && strings.HasPrefix(mediaType, "application/") {
+		mediaType = "application/" + mediaType[i+1:]
+	}
+
+	for _, c := range r.codecs {
+		if slices.Contains(c.MediaTypes(), mediaType) {
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
+// Returns the codec most preferred by an Accept header, breaking ties by the
+// order of the registry. An empty header accepts the first codec, while one
+// accepting none of the codecs returns false
+func (r *Registry) Negotiate(accept string) (Codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return r.codecs[0], true
+	}
+
+	ranges := parseAccept(accept)
+	var best Codec
+	bestQuality := 0.0
+	for _, c := range r.codecs {
+		if quality := acceptQuality(ranges, c.MediaTypes()); quality > bestQuality {
+			best, bestQuality = c, quality
+		}
+	}
+
+	return best, best != nil
+}
+
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// Parses the media ranges of an Accept header, skipping any that are malformed
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+
+		ranges = append(ranges, mediaRange{mediaType, quality})
+	}
+
+	return ranges
+}
+
+// Returns the quality the client gives to the best of mediaTypes. Each is
+// given the quality of the most specific range matching it, so "*/*;q=0.5,
+// application/cbor;q=0" refuses CBOR
+func acceptQuality(ranges []mediaRange, mediaTypes []string) float64 {
+	best := 0.0
+	for _, mediaType := range mediaTypes {
+		kind, _, _ := strings.Cut(mediaType, "/")
+		specificity, quality := -1, 0.0
+		for _, r := range ranges {
+			var s int
+			switch r.mediaType {
+			case mediaType:
+				s = 2
+			case kind + "/*":
+				s = 1
+			case "*/*":
+				s = 0
+			default:
+				continue
+			}
+
+			if s > specificity {
+				specificity, quality = s, r.quality
+			}
+		}
+
+		best = max(best, quality)
+	}
+
+	return best
+}
+
+// Reports whether err was caused by the content of a body, rather than by
+// failing to read it, for decoders which prefix their errors with prefix
+func isContentError(err error, prefix string) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		strings.HasPrefix(err.Error(), prefix)
+}
+
+type contextKey struct{}
+
+// Attaches the codec negotiated for a request to ctx. It can be retrieved with
+// FromContext
+func NewContext(ctx context.Context, c Codec) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// Returns the codec negotiated for a request, or JSON if none was
+func FromContext(ctx context.Context) Codec {
+	if c, ok := ctx.Value(contextKey{}).(Codec); ok {
+		return c
+	}
+
+	return JSON
+}
@@ -0,0 +1,181 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go-chi-api/internal/problem"
+)
+
+// Encodes and decodes JSON, describing errors by line and column
+var JSON Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) MediaTypes() []string {
+	return []string{"application/json"}
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+var errTrailingData = errors.New("Unexpected data after the JSON value")
+
+func (jsonCodec) Decode(r io.Reader, v any, opts DecodeOptions) error {
+	body := &lineCountingReader{reader: r}
+	decoder := json.NewDecoder(body)
+	if opts.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	err := decoder.Decode(v)
+	offset := decoder.InputOffset()
+	if err == nil {
+		// Anything but whitespace after the value is an error
+		var trailing json.RawMessage
+		if trailingErr := decoder.Decode(&trailing); !errors.Is(trailingErr, io.EOF) {
+			err = errTrailingData
+			if trailingErr != nil && !isJsonContentError(trailingErr) {
+				err = trailingErr
+			}
+		}
+	}
+
+	if err == nil || !isJsonContentError(err) {
+		return err
+	}
+
+	return jsonDecodeError(err, body, offset)
+}
+
+// Reports whether err was caused by the content of the body, rather than by
+// failing to read it
+func isJsonContentError(err error) bool {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, errTrailingData) ||
+		errors.As(err, &syntaxError) ||
+		errors.As(err, &typeError) ||
+		strings.HasPrefix(err.Error(), "json: unknown field ")
+}
+
+// Describes a decoding error by where in the body it occurred. offset is where
+// the decoder stopped reading the value
+func jsonDecodeError(err error, body *lineCountingReader, offset int64) *DecodeError {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return &DecodeError{Detail: "The request body is empty", Err: err}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &DecodeError{Detail: "The request body ends " + body.position(offset) + " before the JSON value is complete", Err: err}
+	case errors.Is(err, errTrailingData):
+		return &DecodeError{Detail: "The request body has unexpected data after the JSON value " + body.position(offset), Err: err}
+	case errors.As(err, &syntaxError):
+		// Offsets count the bytes read up to and including the invalid one
+		return &DecodeError{Detail: fmt.Sprintf("The request body is not valid JSON %s: %s", body.position(syntaxError.Offset-1), syntaxError), Err: err}
+	case errors.As(err, &typeError):
+		if typeError.Field == "" {
+			return &DecodeError{Detail: "The request body must be a JSON " + jsonTypeName(typeError.Type.Kind()), Err: err}
+		}
+
+		// The offset is of the end of the value, as its start is not reported
+		return &DecodeError{
+			Detail: "The request body has a field of the wrong type ending " + body.position(typeError.Offset-1),
+			Field: &problem.FieldError{
+				Pointer: fieldPointer(typeError.Field),
+				Detail:  fmt.Sprintf("%s must be a %s", typeError.Field, jsonTypeName(typeError.Type.Kind())),
+			},
+			Err: err,
+		}
+	}
+
+	// The decoder reports unknown fields only by message
+	field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+	return &DecodeError{
+		Detail: "The request body has an unknown field " + body.position(offset),
+		Field:  unknownField(field),
+		Err:    err,
+	}
+}
+
+func unknownField(field string) *problem.FieldError {
+	return &problem.FieldError{
+		Pointer: fieldPointer(field),
+		Detail:  field + " is not a recognized field",
+	}
+}
+
+// Converts a field path reported by a decoder, such as "items.0.name", to a
+// JSON pointer
+func fieldPointer(field string) string {
+	escape := strings.NewReplacer("~", "~0", "/", "~1")
+	var pointer strings.Builder
+	for _, token := range strings.Split(field, ".") {
+		pointer.WriteString("/")
+		pointer.WriteString(escape.Replace(token))
+	}
+
+	return pointer.String()
+}
+
+// Names the JSON type a Go kind is decoded from
+func jsonTypeName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return "value"
+	}
+}
+
+// Records where each line of the body starts as it is read, so that offsets
+// reported by the decoder can be given as a line and column without buffering
+// the body
+type lineCountingReader struct {
+	reader     io.Reader
+	read       int64
+	lineStarts []int64
+}
+
+func (r *lineCountingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			r.lineStarts = append(r.lineStarts, r.read+int64(i)+1)
+		}
+	}
+	r.read += int64(n)
+
+	return n, err
+}
+
+// Describes the position of the byte at offset, counting lines and columns
+// from 1
+func (r *lineCountingReader) position(offset int64) string {
+	line := sort.Search(len(r.lineStarts), func(i int) bool { return r.lineStarts[i] > offset })
+	start := int64(0)
+	if line > 0 {
+		start = r.lineStarts[line-1]
+	}
+
+	return fmt.Sprintf("at line %d, column %d", line+1, offset-start+1)
+}
@@ -0,0 +1,72 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Encodes and decodes MessagePack
+var MessagePack Codec = msgpackCodec{}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) MediaTypes() []string {
+	return []string{"application/msgpack", "application/vnd.msgpack", "application/x-msgpack"}
+}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	encoder.SetCustomStructTag("json")
+	encoder.UseCompactInts(true)
+	encoder.SetSortMapKeys(true)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Decode(r io.Reader, v any, opts DecodeOptions) error {
+	decoder := msgpack.NewDecoder(r)
+	decoder.SetCustomStructTag("json")
+	decoder.DisallowUnknownFields(opts.DisallowUnknownFields)
+
+	err := decoder.Decode(v)
+	if err == nil {
+		var trailing msgpack.RawMessage
+		if trailingErr := decoder.Decode(&trailing); !errors.Is(trailingErr, io.EOF) {
+			if trailingErr == nil || isContentError(trailingErr, "msgpack: ") {
+				return &DecodeError{Detail: "The request body has unexpected data after the MessagePack value", Err: trailingErr}
+			}
+			return trailingErr
+		}
+	}
+
+	if err == nil || !isContentError(err, "msgpack: ") {
+		return err
+	}
+
+	return msgpackDecodeError(err)
+}
+
+func msgpackDecodeError(err error) *DecodeError {
+	switch {
+	case errors.Is(err, io.EOF):
+		return &DecodeError{Detail: "The request body is empty", Err: err}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &DecodeError{Detail: "The request body ends before the MessagePack value is complete", Err: err}
+	}
+
+	// The decoder reports unknown fields only by message
+	if field, ok := strings.CutPrefix(err.Error(), "msgpack: unknown field "); ok {
+		field = strings.Trim(field, `"`)
+		return &DecodeError{Detail: "The request body has an unknown field", Field: unknownField(field), Err: err}
+	}
+
+	return &DecodeError{Detail: "The request body is not valid MessagePack: " + err.Error(), Err: err}
+}
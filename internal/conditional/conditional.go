@@ -0,0 +1,127 @@
+// Package conditional evaluates the preconditions of conditional requests, as
+// defined by RFC 9110 section 13, against the validators of a resource's
+// current representation
+package conditional
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The outcome of evaluating a request's preconditions
+type Result int
+
+const (
+	// The request should be handled as if it were unconditional
+	Proceed Result = iota
+
+	// A GET or HEAD request's cached representation is current, so the
+	// response should be 304 Not Modified
+	NotModified
+
+	// A precondition is false, so the response should be 412 Precondition
+	// Failed
+	PreconditionFailed
+)
+
+// Returns a strong entity tag for a representation, derived from its content
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// Returns a weak version of an entity tag, for representations which are
+// equivalent to the original but not identical to it
+func Weak(etag string) string {
+	if strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return "W/" + etag
+}
+
+// Sets the Last-Modified header, to the second as HTTP dates allow
+func SetLastModified(h http.Header, t time.Time) {
+	h.Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+// Evaluates the request's preconditions in the order of RFC 9110 section
+// 13.2.2, against the current representation's entity tag and modification
+// time. Either may be empty or zero if unknown. A resource with neither is
+// treated as having no current representation, so only "*" conditions apply
+func Check(r *http.Request, etag string, lastModified time.Time) Result {
+	exists := etag != "" || !lastModified.IsZero()
+	lastModified = lastModified.Truncate(time.Second)
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if ifMatch := r.Header.Values("If-Match"); len(ifMatch) > 0 {
+		if !matches(ifMatch, etag, exists, strongEqual) {
+			return PreconditionFailed
+		}
+	} else if since, ok := parseDate(r.Header.Get("If-Unmodified-Since")); ok && !lastModified.IsZero() {
+		if lastModified.After(since) {
+			return PreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := r.Header.Values("If-None-Match"); len(ifNoneMatch) > 0 {
+		if matches(ifNoneMatch, etag, exists, weakEqual) {
+			if safe {
+				return NotModified
+			}
+			return PreconditionFailed
+		}
+	} else if since, ok := parseDate(r.Header.Get("If-Modified-Since")); ok && safe && !lastModified.IsZero() {
+		if !lastModified.After(since) {
+			return NotModified
+		}
+	}
+
+	return Proceed
+}
+
+// Reports whether any entity tag in the header values matches etag. "*"
+// matches any current representation
+func matches(values []string, etag string, exists bool, equal func(a, b string) bool) bool {
+	for _, value := range values {
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" {
+				if exists {
+					return true
+				}
+				continue
+			}
+
+			if etag != "" && equal(candidate, etag) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Strong comparison requires both tags to be strong and identical
+func strongEqual(a, b string) bool {
+	return !strings.HasPrefix(a, "W/") && !strings.HasPrefix(b, "W/") && a == b
+}
+
+// Weak comparison ignores whether either tag is weak
+func weakEqual(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// Parses an HTTP date, ignoring the header if it is missing or invalid as RFC
+// 9110 requires
+func parseDate(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	t, err := http.ParseTime(value)
+	return t, err == nil
+}
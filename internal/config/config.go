@@ -0,0 +1,333 @@
+package config
+
+import (
+	"slices"
+	"time"
+)
+
+// Each leaf field is populated from the sources described on Load. The tags
+// used are:
+//
+//	env      environment variable name, also used to derive the flag name
+//	flag     overrides the derived flag name
+//	default  value used when no source provides one
+//	secret   when "true", the value is redacted when printed
+//	validate go-playground/validator rules checked after loading
+type (
+	Config struct {
+		Server         Server         `yaml:"server" toml:"server"`
+		Compression    Compression    `yaml:"compression" toml:"compression"`
+		Idempotency    Idempotency    `yaml:"idempotency" toml:"idempotency"`
+		RateLimit      RateLimit      `yaml:"rate_limit" toml:"rate_limit"`
+		Cors           Cors           `yaml:"cors" toml:"cors"`
+		Security       Security       `yaml:"security" toml:"security"`
+		Health         Health         `yaml:"health" toml:"health"`
+		Database       Database       `yaml:"database" toml:"database"`
+		Authentication Authentication `yaml:"authentication" toml:"authentication"`
+		Logging        Logging        `yaml:"logging" toml:"logging"`
+		Telemetry      Telemetry      `yaml:"telemetry" toml:"telemetry"`
+		Runtime        Runtime        `yaml:"runtime" toml:"runtime"`
+
+		// The configuration file this was loaded from, if any
+		file string
+	}
+
+	Server struct {
+		Port            int           `yaml:"port" toml:"port" env:"PORT" validate:"required,min=1,max=65535"`
+		ReadTimeout     time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"SERVER_READ_TIMEOUT" default:"5s" validate:"gt=0"`
+		WriteTimeout    time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" default:"30s" validate:"gt=0"`
+		IdleTimeout     time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" default:"1m" validate:"gt=0"`
+		RequestTimeout  time.Duration `yaml:"request_timeout" toml:"request_timeout" env:"SERVER_REQUEST_TIMEOUT" default:"1m" validate:"gt=0"`
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" default:"1m" validate:"gt=0"`
+
+		// The largest request body accepted, unless a route sets its own limit
+		MaxBodyBytes int64 `yaml:"max_body_bytes" toml:"max_body_bytes" env:"SERVER_MAX_BODY_BYTES" default:"1048576" validate:"min=1"`
+
+		// How long Shutdown reports the server as not ready before it stops
+		// accepting connections, giving load balancers time to stop routing
+		// to it
+		DrainDelay time.Duration `yaml:"drain_delay" toml:"drain_delay" env:"SERVER_DRAIN_DELAY" default:"0s" validate:"gte=0"`
+
+		// The proxies, as CIDRs, whose X-Forwarded-For and X-Real-IP headers
+		// identify the client. Requests from any other address are identified
+		// by it, whatever headers they carry
+		TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"SERVER_TRUSTED_PROXIES" default:"127.0.0.0/8,::1/128" validate:"dive,cidr"`
+
+		// Whether HTTP/2 is served without TLS, as it is by proxies which
+		// terminate TLS themselves. HTTP/2 is always served with TLS
+		H2C bool `yaml:"h2c" toml:"h2c" env:"SERVER_H2C"`
+
+		TLS TLS `yaml:"tls" toml:"tls"`
+	}
+
+	// Serving HTTPS, which is enabled by setting both CertFile and KeyFile.
+	// Certificates are reloaded when either file changes, so they can be
+	// renewed without a restart
+	TLS struct {
+		CertFile string `yaml:"cert_file" toml:"cert_file" env:"TLS_CERT_FILE" validate:"required_with=KeyFile,omitempty,file"`
+		KeyFile  string `yaml:"key_file" toml:"key_file" env:"TLS_KEY_FILE" validate:"required_with=CertFile,omitempty,file"`
+
+		// How often the files are checked for changes, at most
+		ReloadInterval time.Duration `yaml:"reload_interval" toml:"reload_interval" env:"TLS_RELOAD_INTERVAL" default:"10s" validate:"gte=0"`
+
+		MinVersion string `yaml:"min_version" toml:"min_version" env:"TLS_MIN_VERSION" default:"1.2" validate:"oneof=1.2 1.3"`
+
+		// The TLS 1.2 cipher suites allowed, by their Go names, such as
+		// TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. Go's defaults when empty.
+		// TLS 1.3 suites are not configurable, so naming one is an error
+		CipherSuites []string `yaml:"cipher_suites" toml:"cipher_suites" env:"TLS_CIPHER_SUITES"`
+
+		// Whether clients must present a certificate signed by ClientCAFile:
+		// none, request (any certificate, unverified), verify_if_given or
+		// require_and_verify
+		ClientAuth   string `yaml:"client_auth" toml:"client_auth" env:"TLS_CLIENT_AUTH" default:"none" validate:"oneof=none request verify_if_given require_and_verify"`
+		ClientCAFile string `yaml:"client_ca_file" toml:"client_ca_file" env:"TLS_CLIENT_CA_FILE" validate:"omitempty,file"`
+	}
+
+	// Compression of response bodies, negotiated by Accept-Encoding
+	Compression struct {
+		// The encodings offered, in order of the server's preference
+		Encodings []string `yaml:"encodings" toml:"encodings" env:"COMPRESSION_ENCODINGS" default:"zstd,br,gzip" validate:"dive,oneof=zstd br gzip"`
+
+		// Bodies smaller than this are sent uncompressed, as compressing them
+		// saves too little to be worth it
+		MinSize int `yaml:"min_size" toml:"min_size" env:"COMPRESSION_MIN_SIZE" default:"1024" validate:"min=0"`
+
+		// The media types compressed. A type ending in /* matches any subtype
+		ContentTypes []string `yaml:"content_types" toml:"content_types" env:"COMPRESSION_CONTENT_TYPES" default:"application/json,application/problem+json,application/cbor,application/msgpack,application/javascript,image/svg+xml,text/*"`
+	}
+
+	// Replaying the responses to requests retried with the same
+	// Idempotency-Key
+	Idempotency struct {
+		// Where responses are kept. A memory store only replays responses
+		// given by the same instance
+		Store string `yaml:"store" toml:"store" env:"IDEMPOTENCY_STORE" default:"postgres" validate:"oneof=memory postgres"`
+
+		// How long a response is kept for replay
+		TTL time.Duration `yaml:"ttl" toml:"ttl" env:"IDEMPOTENCY_TTL" default:"24h" validate:"gt=0"`
+	}
+
+	// Limits on the requests made to each group of routes. The health checks
+	// are not limited, so probes are never turned away
+	RateLimit struct {
+		// Where the requests made are counted. A memory store counts only the
+		// requests handled by the same instance. Unlike the limits, the store
+		// is not reloaded
+		Store string `yaml:"store" toml:"store" env:"RATE_LIMIT_STORE" default:"memory" validate:"oneof=memory postgres"`
+
+		Api  ApiRateLimit  `yaml:"api" toml:"api"`
+		Auth AuthRateLimit `yaml:"auth" toml:"auth"`
+	}
+
+	// The limit of a group of routes. Requests are counted per key, which
+	// combines each part of KeyBy: the client's ip, the user's id, the
+	// X-API-Key header, or the route. A user or API key falls back to the ip
+	// when the request has none. Requests of 0 disables the limit
+	RateLimitPolicy struct {
+		Algorithm string
+		Requests  int
+		Period    time.Duration
+		KeyBy     []string
+	}
+
+	// The limit of authenticated routes, converted to a RateLimitPolicy
+	ApiRateLimit struct {
+		Algorithm string        `yaml:"algorithm" toml:"algorithm" env:"RATE_LIMIT_API_ALGORITHM" default:"token_bucket" validate:"oneof=token_bucket sliding_window"`
+		Requests  int           `yaml:"requests" toml:"requests" env:"RATE_LIMIT_API_REQUESTS" default:"600" validate:"min=0"`
+		Period    time.Duration `yaml:"period" toml:"period" env:"RATE_LIMIT_API_PERIOD" default:"1m" validate:"gt=0"`
+		KeyBy     []string      `yaml:"key_by" toml:"key_by" env:"RATE_LIMIT_API_KEY_BY" default:"user" validate:"min=1,dive,oneof=ip user api_key route"`
+	}
+
+	// The limit of registering and logging in, converted to a RateLimitPolicy
+	AuthRateLimit struct {
+		Algorithm string        `yaml:"algorithm" toml:"algorithm" env:"RATE_LIMIT_AUTH_ALGORITHM" default:"sliding_window" validate:"oneof=token_bucket sliding_window"`
+		Requests  int           `yaml:"requests" toml:"requests" env:"RATE_LIMIT_AUTH_REQUESTS" default:"20" validate:"min=0"`
+		Period    time.Duration `yaml:"period" toml:"period" env:"RATE_LIMIT_AUTH_PERIOD" default:"1m" validate:"gt=0"`
+		KeyBy     []string      `yaml:"key_by" toml:"key_by" env:"RATE_LIMIT_AUTH_KEY_BY" default:"ip" validate:"min=1,dive,oneof=ip user api_key route"`
+	}
+
+	// Cross-origin requests from browsers, allowed separately to each group
+	// of routes
+	Cors struct {
+		Api  ApiCors  `yaml:"api" toml:"api"`
+		Docs DocsCors `yaml:"docs" toml:"docs"`
+	}
+
+	// The cross-origin requests allowed to a group of routes. AllowedOrigins
+	// lists origins such as https://app.example.com, which may use * in place
+	// of their subdomains, as in https://*.example.com, or be * to allow any
+	// origin. Empty disallows cross-origin requests. AllowCredentials allows
+	// requests to include the authentication cookie, so cannot be set when
+	// any origin is allowed. ExposedHeaders lists the response headers pages
+	// may read, beyond those always exposed, and MaxAge how long browsers may
+	// cache the answer to a preflight request
+	CorsPolicy struct {
+		AllowedOrigins   []string
+		AllowCredentials bool
+		AllowedMethods   []string
+		AllowedHeaders   []string
+		ExposedHeaders   []string
+		MaxAge           time.Duration
+	}
+
+	// The cross-origin requests allowed to the API, converted to a CorsPolicy
+	ApiCors struct {
+		AllowedOrigins   []string      `yaml:"allowed_origins" toml:"allowed_origins" env:"CORS_API_ALLOWED_ORIGINS" validate:"no_wildcard_with=AllowCredentials"`
+		AllowCredentials bool          `yaml:"allow_credentials" toml:"allow_credentials" env:"CORS_API_ALLOW_CREDENTIALS" default:"true"`
+		AllowedMethods   []string      `yaml:"allowed_methods" toml:"allowed_methods" env:"CORS_API_ALLOWED_METHODS" default:"GET,HEAD,POST,PUT,PATCH,DELETE" validate:"min=1"`
+		AllowedHeaders   []string      `yaml:"allowed_headers" toml:"allowed_headers" env:"CORS_API_ALLOWED_HEADERS" default:"Accept,Accept-Language,Content-Type,Idempotency-Key,X-CSRF-Token,If-Match,If-Modified-Since,If-None-Match,If-Unmodified-Since,X-API-Key"`
+		ExposedHeaders   []string      `yaml:"exposed_headers" toml:"exposed_headers" env:"CORS_API_EXPOSED_HEADERS" default:"ETag,Idempotent-Replayed,Location,RateLimit-Limit,RateLimit-Policy,RateLimit-Remaining,RateLimit-Reset,Retry-After,X-Request-Id"`
+		MaxAge           time.Duration `yaml:"max_age" toml:"max_age" env:"CORS_API_MAX_AGE" default:"10m" validate:"gte=0"`
+	}
+
+	// The cross-origin requests allowed to the Swagger UI and the document it
+	// displays, converted to a CorsPolicy. None are allowed by default
+	DocsCors struct {
+		AllowedOrigins   []string      `yaml:"allowed_origins" toml:"allowed_origins" env:"CORS_DOCS_ALLOWED_ORIGINS" validate:"no_wildcard_with=AllowCredentials"`
+		AllowCredentials bool          `yaml:"allow_credentials" toml:"allow_credentials" env:"CORS_DOCS_ALLOW_CREDENTIALS"`
+		AllowedMethods   []string      `yaml:"allowed_methods" toml:"allowed_methods" env:"CORS_DOCS_ALLOWED_METHODS" default:"GET,HEAD" validate:"min=1"`
+		AllowedHeaders   []string      `yaml:"allowed_headers" toml:"allowed_headers" env:"CORS_DOCS_ALLOWED_HEADERS" default:"Accept"`
+		ExposedHeaders   []string      `yaml:"exposed_headers" toml:"exposed_headers" env:"CORS_DOCS_EXPOSED_HEADERS"`
+		MaxAge           time.Duration `yaml:"max_age" toml:"max_age" env:"CORS_DOCS_MAX_AGE" default:"10m" validate:"gte=0"`
+	}
+
+	// Hardening headers sent with every response
+	Security struct {
+		// How long browsers should only use HTTPS, or 0 to not say
+		HstsMaxAge            time.Duration `yaml:"hsts_max_age" toml:"hsts_max_age" env:"SECURITY_HSTS_MAX_AGE" default:"8760h" validate:"gte=0"`
+		HstsIncludeSubdomains bool          `yaml:"hsts_include_subdomains" toml:"hsts_include_subdomains" env:"SECURITY_HSTS_INCLUDE_SUBDOMAINS"`
+		ReferrerPolicy        string        `yaml:"referrer_policy" toml:"referrer_policy" env:"SECURITY_REFERRER_POLICY" default:"no-referrer"`
+		FrameOptions          string        `yaml:"frame_options" toml:"frame_options" env:"SECURITY_FRAME_OPTIONS" default:"DENY" validate:"omitempty,oneof=DENY SAMEORIGIN"`
+
+		// The Content-Security-Policy of each group of routes. API responses
+		// are data, which should never load anything, while the Swagger UI
+		// needs its own scripts and styles
+		ApiContentSecurityPolicy  string `yaml:"api_content_security_policy" toml:"api_content_security_policy" env:"SECURITY_API_CSP" default:"default-src 'none'; frame-ancestors 'none'"`
+		DocsContentSecurityPolicy string `yaml:"docs_content_security_policy" toml:"docs_content_security_policy" env:"SECURITY_DOCS_CSP" default:"default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; frame-ancestors 'none'"`
+	}
+
+	// Defaults for the checks reported by the health endpoints. A check's
+	// result is reused for CacheFor, so frequent probes do not load the
+	// dependencies being checked
+	Health struct {
+		Timeout  time.Duration `yaml:"timeout" toml:"timeout" env:"HEALTH_CHECK_TIMEOUT" default:"1s" validate:"gt=0"`
+		CacheFor time.Duration `yaml:"cache_for" toml:"cache_for" env:"HEALTH_CHECK_CACHE_FOR" default:"2s" validate:"gte=0"`
+	}
+
+	Database struct {
+		Host     string `yaml:"host" toml:"host" env:"DB_HOST" validate:"required"`
+		Port     int    `yaml:"port" toml:"port" env:"DB_PORT" default:"5432" validate:"min=1,max=65535"`
+		Name     string `yaml:"name" toml:"name" env:"DB_DATABASE" validate:"required"`
+		Username string `yaml:"username" toml:"username" env:"DB_USERNAME" validate:"required"`
+		Password string `yaml:"password" toml:"password" env:"DB_PASSWORD" secret:"true"`
+		SSLMode  string `yaml:"ssl_mode" toml:"ssl_mode" env:"DB_SSLMODE" default:"disable" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+
+		// The maximum number of open connections, or 0 for no limit
+		MaxOpenConnections int            `yaml:"max_open_connections" toml:"max_open_connections" env:"DB_MAX_OPEN_CONNECTIONS" validate:"min=0"`
+		Health             DatabaseHealth `yaml:"health" toml:"health"`
+	}
+
+	// Thresholds beyond which the database health check reports the database
+	// as degraded
+	DatabaseHealth struct {
+		MaxLatency         time.Duration `yaml:"max_latency" toml:"max_latency" env:"DB_HEALTH_MAX_LATENCY" default:"250ms" validate:"gt=0"`
+		MaxReplicationLag  time.Duration `yaml:"max_replication_lag" toml:"max_replication_lag" env:"DB_HEALTH_MAX_REPLICATION_LAG" default:"30s" validate:"gt=0"`
+		MaxPoolUtilization float64       `yaml:"max_pool_utilization" toml:"max_pool_utilization" env:"DB_HEALTH_MAX_POOL_UTILIZATION" default:"0.9" validate:"gt=0,max=1"`
+	}
+
+	Authentication struct {
+		JwtSecret      string        `yaml:"jwt_secret" toml:"jwt_secret" env:"JWT_SECRET" secret:"true" validate:"required"`
+		CookieLifetime time.Duration `yaml:"cookie_lifetime" toml:"cookie_lifetime" env:"AUTH_COOKIE_LIFETIME" default:"72h" validate:"gt=0"`
+		Argon2         Argon2        `yaml:"argon2" toml:"argon2"`
+	}
+
+	// Cost parameters for newly hashed passwords. Existing hashes using other
+	// parameters still verify, and are flagged for rehashing
+	Argon2 struct {
+		Memory      uint32 `yaml:"memory" toml:"memory" env:"ARGON2_MEMORY" default:"12288" validate:"min=8"`
+		Iterations  uint32 `yaml:"iterations" toml:"iterations" env:"ARGON2_ITERATIONS" default:"3" validate:"min=1"`
+		Parallelism uint8  `yaml:"parallelism" toml:"parallelism" env:"ARGON2_PARALLELISM" default:"1" validate:"min=1"`
+		SaltLength  uint8  `yaml:"salt_length" toml:"salt_length" env:"ARGON2_SALT_LENGTH" default:"16" validate:"min=8"`
+		KeyLength   uint32 `yaml:"key_length" toml:"key_length" env:"ARGON2_KEY_LENGTH" default:"32" validate:"min=16"`
+	}
+
+	// The level logged at is a runtime setting, Runtime.LogLevel
+	Logging struct {
+		Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" default:"json" validate:"oneof=json text"`
+	}
+
+	// Named after the standard OpenTelemetry SDK environment variables. The
+	// sampler's ratio is a runtime setting, Runtime.TraceSampleRatio
+	Telemetry struct {
+		ServiceName     string     `yaml:"service_name" toml:"service_name" env:"OTEL_SERVICE_NAME" default:"go-chi-api" validate:"required"`
+		TracesExporter  string     `yaml:"traces_exporter" toml:"traces_exporter" env:"OTEL_TRACES_EXPORTER" default:"otlp" validate:"oneof=otlp console none"`
+		MetricsExporter string     `yaml:"metrics_exporter" toml:"metrics_exporter" env:"OTEL_METRICS_EXPORTER" default:"otlp" validate:"oneof=otlp prometheus console none"`
+		LogsExporter    string     `yaml:"logs_exporter" toml:"logs_exporter" env:"OTEL_LOGS_EXPORTER" default:"otlp" validate:"oneof=otlp console none"`
+		TracesSampler   string     `yaml:"traces_sampler" toml:"traces_sampler" env:"OTEL_TRACES_SAMPLER" default:"parentbased_traceidratio" validate:"oneof=always_on always_off traceidratio parentbased_always_on parentbased_always_off parentbased_traceidratio"`
+		Otlp            Otlp       `yaml:"otlp" toml:"otlp"`
+		Prometheus      Prometheus `yaml:"prometheus" toml:"prometheus"`
+	}
+
+	// Settings shared by every OTLP exporter. Any left empty may instead be
+	// given by the signal specific variables, such as
+	// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, which the exporters read themselves
+	Otlp struct {
+		Protocol          string `yaml:"protocol" toml:"protocol" env:"OTEL_EXPORTER_OTLP_PROTOCOL" default:"http/protobuf" validate:"oneof=grpc http/protobuf"`
+		Endpoint          string `yaml:"endpoint" toml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" validate:"omitempty,url"`
+		Headers           string `yaml:"headers" toml:"headers" env:"OTEL_EXPORTER_OTLP_HEADERS" secret:"true"`
+		Compression       string `yaml:"compression" toml:"compression" env:"OTEL_EXPORTER_OTLP_COMPRESSION" validate:"omitempty,oneof=gzip none"`
+		Insecure          bool   `yaml:"insecure" toml:"insecure" env:"OTEL_EXPORTER_OTLP_INSECURE"`
+		Certificate       string `yaml:"certificate" toml:"certificate" env:"OTEL_EXPORTER_OTLP_CERTIFICATE" validate:"omitempty,file"`
+		ClientCertificate string `yaml:"client_certificate" toml:"client_certificate" env:"OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE" validate:"required_with=ClientKey,omitempty,file"`
+		ClientKey         string `yaml:"client_key" toml:"client_key" env:"OTEL_EXPORTER_OTLP_CLIENT_KEY" validate:"required_with=ClientCertificate,omitempty,file"`
+	}
+
+	// The admin listener, serving the health checks in full, and /metrics when
+	// the metrics exporter is prometheus. It is separate from the API so that
+	// neither need be exposed publicly
+	Prometheus struct {
+		Host string `yaml:"host" toml:"host" env:"OTEL_EXPORTER_PROMETHEUS_HOST" default:"localhost"`
+		Port int    `yaml:"port" toml:"port" env:"OTEL_EXPORTER_PROMETHEUS_PORT" default:"9464" validate:"min=1,max=65535"`
+	}
+
+	// Settings which Store.Reload applies to the running process, as it does
+	// the rate limit of each group and CORS. Every other setting keeps the
+	// value it had at startup
+	Runtime struct {
+		LogLevel         string   `yaml:"log_level" toml:"log_level" env:"LOG_LEVEL" default:"info" validate:"oneof=debug info warn error"`
+		TraceSampleRatio float64  `yaml:"trace_sample_ratio" toml:"trace_sample_ratio" env:"OTEL_TRACES_SAMPLER_ARG" default:"1" validate:"min=0,max=1"`
+		Features         []string `yaml:"features" toml:"features" env:"FEATURES"`
+	}
+)
+
+// Returns the limit of the named group of routes, which is disabled if there
+// is no such group
+func (r *RateLimit) Policy(group string) RateLimitPolicy {
+	switch group {
+	case "api":
+		return RateLimitPolicy(r.Api)
+	case "auth":
+		return RateLimitPolicy(r.Auth)
+	default:
+		return RateLimitPolicy{}
+	}
+}
+
+// Returns the cross-origin requests allowed to the named group of routes,
+// which are none if there is no such group
+func (c *Cors) Policy(group string) CorsPolicy {
+	switch group {
+	case "api":
+		return CorsPolicy(c.Api)
+	case "docs":
+		return CorsPolicy(c.Docs)
+	default:
+		return CorsPolicy{}
+	}
+}
+
+// Reports whether the named feature flag is enabled
+func (r *Runtime) FeatureEnabled(name string) bool {
+	return slices.Contains(r.Features, name)
+}
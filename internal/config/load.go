@@ -0,0 +1,439 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-playground/validator/v10"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	redacted = "[REDACTED]"
+
+	configFileEnv  = "CONFIG_FILE"
+	configFileFlag = "config"
+)
+
+var (
+	ErrInvalidConfig         = errors.New("Invalid configuration")
+	ErrUnsupportedConfigFile = errors.New("Configuration file must have a .yaml, .yml or .toml extension")
+
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// A single configurable value, addressed within a Config
+type field struct {
+	key    string
+	name   string
+	env    string
+	flag   string
+	def    string
+	secret bool
+	value  reflect.Value
+}
+
+// Returns a configuration holding only default values. It is not validated
+func Default() *Config {
+	cfg := &Config{}
+	for _, f := range fields(reflect.ValueOf(cfg).Elem(), "", "") {
+		if f.def == "" {
+			continue
+		}
+
+		if err := f.set(f.def); err != nil {
+			panic(err)
+		}
+	}
+
+	return cfg
+}
+
+// Loads the configuration from the following sources, where later sources take
+// precedence over earlier ones:
+//
+//  1. field defaults
+//  2. the YAML or TOML file given by -config or $CONFIG_FILE
+//  3. variables in .env, which never override the real environment
+//  4. environment variables, or the contents of the file named by the
+//     variable with a _FILE suffix
+//  5. command line flags in args
+//
+// Every problem found is reported in the returned error, not just the first
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+	configFields := fields(reflect.ValueOf(cfg).Elem(), "", "")
+
+	flags := flag.NewFlagSet("go-chi-api", flag.ContinueOnError)
+	configFile := flags.String(configFileFlag, "", fmt.Sprintf("Path to a YAML or TOML configuration file (overrides $%s)", configFileEnv))
+	byFlag := make(map[string]field, len(configFields))
+	for _, f := range configFields {
+		flags.String(f.flag, f.def, fmt.Sprintf("Overrides $%s", f.env))
+		byFlag[f.flag] = f
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv(configFileEnv)
+	}
+
+	if path != "" {
+		if err := decodeFile(path, cfg); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+		}
+		cfg.file = path
+	}
+
+	var errs []error
+	for _, f := range configFields {
+		raw, ok, err := lookupEnv(f.env)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if ok {
+			if err := f.set(raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+			}
+		}
+	}
+
+	flags.Visit(func(fl *flag.Flag) {
+		f, ok := byFlag[fl.Name]
+		if !ok {
+			return
+		}
+
+		if err := f.set(fl.Value.String()); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", f.flag, err))
+		}
+	})
+
+	errs = append(errs, validate(cfg, configFields)...)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w:\n%w", ErrInvalidConfig, errors.Join(errs...))
+	}
+
+	return cfg, nil
+}
+
+// Writes the configuration as YAML, suitable for use as a configuration file.
+// Secrets are redacted, and each value is annotated with its variable name
+func (c *Config) Write(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range fields(reflect.ValueOf(c).Elem(), "", "") {
+		node := root
+		for _, key := range strings.Split(f.key, ".") {
+			node = childNode(node, key)
+		}
+
+		node.LineComment = "$" + f.env
+		switch f.value.Kind() {
+		case reflect.Slice:
+			node.Kind = yaml.SequenceNode
+			node.Style = yaml.FlowStyle
+			for i := 0; i < f.value.Len(); i++ {
+				node.Content = append(node.Content, &yaml.Node{
+					Kind:  yaml.ScalarNode,
+					Tag:   "!!str",
+					Value: f.value.Index(i).String(),
+				})
+			}
+		case reflect.String:
+			node.Kind = yaml.ScalarNode
+			node.Tag = "!!str"
+			node.Value = f.String()
+		default:
+			node.Kind = yaml.ScalarNode
+			node.Value = f.String()
+		}
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+func fields(v reflect.Value, key string, name string) []field {
+	var result []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		value := v.Field(i)
+		fieldKey := joinPath(key, structField.Tag.Get("yaml"))
+		fieldName := joinPath(name, structField.Name)
+
+		env, ok := structField.Tag.Lookup("env")
+		if !ok {
+			if value.Kind() == reflect.Struct {
+				result = append(result, fields(value, fieldKey, fieldName)...)
+			}
+			continue
+		}
+
+		flagName := structField.Tag.Get("flag")
+		if flagName == "" {
+			flagName = strings.ToLower(strings.ReplaceAll(env, "_", "-"))
+		}
+
+		result = append(result, field{
+			key:    fieldKey,
+			name:   fieldName,
+			env:    env,
+			flag:   flagName,
+			def:    structField.Tag.Get("default"),
+			secret: structField.Tag.Get("secret") == "true",
+			value:  value,
+		})
+	}
+
+	return result
+}
+
+func joinPath(prefix string, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "." + name
+}
+
+func childNode(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, child)
+	return child
+}
+
+// Parses raw according to the field's type, storing the result in the field
+func (f field) set(raw string) error {
+	v := f.value
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		fl, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(fl)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported configuration type %s", v.Type())
+		}
+
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported configuration type %s", v.Type())
+	}
+
+	return nil
+}
+
+// Formats the field's value, redacting it if it is a secret
+func (f field) String() string {
+	if f.secret && !f.value.IsZero() {
+		return redacted
+	}
+
+	if f.value.Type() == durationType {
+		return time.Duration(f.value.Int()).String()
+	}
+
+	if items, ok := f.value.Interface().([]string); ok {
+		return strings.Join(items, ",")
+	}
+
+	return fmt.Sprint(f.value.Interface())
+}
+
+// Looks up the environment variable name, falling back to reading the file
+// named by name_FILE, as is conventional for Docker secrets
+func lookupEnv(name string) (string, bool, error) {
+	value := os.Getenv(name)
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return value, value != "", nil
+	}
+
+	if value != "" {
+		return "", false, fmt.Errorf("%s and %s_FILE cannot both be set", name, name)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s_FILE: %w", name, err)
+	}
+
+	return strings.TrimRight(string(contents), "\r\n"), true, nil
+}
+
+func decodeFile(path string, cfg *Config) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		decoder := yaml.NewDecoder(file)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		metadata, err := toml.DecodeFile(path, cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%s: unknown keys %v", path, undecoded)
+		}
+	default:
+		return ErrUnsupportedConfigFile
+	}
+
+	return nil
+}
+
+func validate(cfg *Config, fields []field) []error {
+	var validationErrors validator.ValidationErrors
+	v := validator.New()
+	if err := v.RegisterValidation("no_wildcard_with", noWildcardWith); err != nil {
+		return []error{err}
+	}
+
+	if err := v.Struct(cfg); !errors.As(err, &validationErrors) {
+		if err != nil {
+			return []error{err}
+		}
+		return nil
+	}
+
+	byName := make(map[string]field, len(fields))
+	for _, f := range fields {
+		byName[f.name] = f
+	}
+
+	errs := make([]error, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		// Elements of a slice are reported with their index, as in
+		// Compression.Encodings[0], which is kept to point out the element
+		name, index, _ := strings.Cut(strings.TrimPrefix(fieldError.StructNamespace(), "Config."), "[")
+		f := byName[name]
+		key := f.key
+		if index != "" {
+			key += "[" + index
+		}
+
+		errs = append(errs, fmt.Errorf("%s ($%s) %s", key, f.env, describe(fieldError)))
+	}
+
+	return errs
+}
+
+// Fails a list including * while the boolean field named by the parameter is
+// set, such as allowing credentials from any origin, which would have the
+// authentication cookie sent by every site the user visits
+func noWildcardWith(fl validator.FieldLevel) bool {
+	other := fl.Parent().FieldByName(fl.Param())
+	if !other.IsValid() || other.Kind() != reflect.Bool || !other.Bool() {
+		return true
+	}
+
+	items, _ := fl.Field().Interface().([]string)
+	return !slices.Contains(items, "*")
+}
+
+func describe(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + fieldError.Param()
+	case "max":
+		return "must be at most " + fieldError.Param()
+	case "gt":
+		return "must be greater than " + fieldError.Param()
+	case "oneof":
+		return "must be one of: " + fieldError.Param()
+	case "url":
+		return "must be a URL"
+	case "cidr":
+		return "must be a CIDR, such as 10.0.0.0/8"
+	case "file":
+		return "must be an existing file"
+	case "required_with":
+		return "is required when " + fieldError.Param() + " is set"
+	case "no_wildcard_with":
+		return "cannot include * when " + fieldError.Param() + " is set"
+	default:
+		return fmt.Sprintf("failed %s validation", fieldError.Tag())
+	}
+}
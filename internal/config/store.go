@@ -0,0 +1,119 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Store holds the current configuration snapshot. Snapshots are never
+// modified; Reload swaps in a new one, so readers may hold on to a snapshot
+// for as long as they need a consistent view
+type Store struct {
+	current atomic.Pointer[Config]
+	args    []string
+
+	mu    sync.Mutex
+	hooks []func(*Config, error)
+}
+
+type contextKey struct{}
+
+// Creates a Store holding cfg. Reloads load the configuration again using
+// args, as Load does
+func NewStore(cfg *Config, args []string) *Store {
+	s := &Store{args: args}
+	s.current.Store(cfg)
+	return s
+}
+
+// Returns the current configuration snapshot
+func (s *Store) Load() *Config {
+	return s.current.Load()
+}
+
+// Registers fn to be called after every reload attempt with the snapshot in
+// effect afterwards, and the error that caused the reload to be rejected, if
+// any
+func (s *Store) OnReload(fn func(cfg *Config, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, fn)
+}
+
+// Loads and validates the configuration again, applying its Runtime section,
+// the rate limit of each group and CORS. If the new configuration is invalid
+// it is rejected, and the current snapshot remains in effect
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.current.Load()
+	loaded, err := Load(s.args)
+	if err != nil {
+		slog.Warn("Configuration reload rejected, keeping current configuration", "error", err)
+	} else {
+		next := *current
+		next.Runtime = loaded.Runtime
+		next.RateLimit.Api = loaded.RateLimit.Api
+		next.RateLimit.Auth = loaded.RateLimit.Auth
+		next.Cors = loaded.Cors
+		s.current.Store(&next)
+		current = &next
+		slog.Info("Configuration reloaded", "log_level", next.Runtime.LogLevel, "features", next.Runtime.Features)
+	}
+
+	for _, hook := range s.hooks {
+		hook(current, err)
+	}
+
+	return err
+}
+
+// Polls the configuration file every interval, reloading whenever its
+// modification time changes, until ctx is done. It does nothing if the
+// configuration was not loaded from a file
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	path := s.Load().file
+	if path == "" {
+		return
+	}
+
+	modTime := func() time.Time {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}
+		}
+		return info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := modTime()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if current := modTime(); !current.Equal(last) {
+				last = current
+				_ = s.Reload()
+			}
+		}
+	}
+}
+
+// Returns a copy of ctx carrying the configuration snapshot cfg
+func NewContext(ctx context.Context, cfg *Config) context.Context {
+	return context.WithValue(ctx, contextKey{}, cfg)
+}
+
+// Returns the configuration snapshot carried by ctx, or nil if there is none
+func FromContext(ctx context.Context) *Config {
+	cfg, _ := ctx.Value(contextKey{}).(*Config)
+	return cfg
+}
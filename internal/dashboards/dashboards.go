@@ -0,0 +1,208 @@
+// Package dashboards defines the Grafana dashboards and Prometheus rules for
+// the service. Queries are built from the metric names in internal/otel, so
+// the generated files under o11y stay in sync with what the service emits
+package dashboards
+
+//go:generate go run go-chi-api/cmd/o11y -out ../../o11y
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+)
+
+// Grafana's UID for the Prometheus datasource, from o11y/grafana-datasources.yaml
+const datasourceUID = "prometheus"
+
+type (
+	Dashboard struct {
+		UID   string
+		Title string
+
+		// Incremented whenever the dashboard changes, so Grafana replaces the
+		// provisioned copy
+		Version     int
+		Description string
+		Variables   []Variable
+		Panels      []Panel
+	}
+
+	// A dashboard variable whose values are those of Label on Metric's series
+	Variable struct {
+		Name   string
+		Label  string
+		Metric string
+	}
+
+	Panel struct {
+		Title       string
+		Description string
+		Unit        string
+		Targets     []Target
+	}
+
+	Target struct {
+		Expr   string
+		Legend string
+
+		// Shows exemplars, linking samples to the traces they were recorded in
+		Exemplar bool
+	}
+)
+
+// Returns the PromQL expressions the dashboard queries, including those
+// selecting the series variables take their values from
+func (d *Dashboard) Exprs() []string {
+	var exprs []string
+	for _, variable := range d.Variables {
+		exprs = append(exprs, variable.Metric)
+	}
+
+	for _, panel := range d.Panels {
+		for _, target := range panel.Targets {
+			exprs = append(exprs, target.Expr)
+		}
+	}
+
+	return exprs
+}
+
+// Returns the dashboard in Grafana's JSON model
+func (d *Dashboard) MarshalJSON() ([]byte, error) {
+	type (
+		datasource struct {
+			Type string `json:"type"`
+			UID  string `json:"uid"`
+		}
+		gridPos struct {
+			H int `json:"h"`
+			W int `json:"w"`
+			X int `json:"x"`
+			Y int `json:"y"`
+		}
+		target struct {
+			RefID        string     `json:"refId"`
+			Datasource   datasource `json:"datasource"`
+			Expr         string     `json:"expr"`
+			LegendFormat string     `json:"legendFormat,omitempty"`
+			Exemplar     bool       `json:"exemplar"`
+		}
+		panel struct {
+			ID          int        `json:"id"`
+			Type        string     `json:"type"`
+			Title       string     `json:"title"`
+			Description string     `json:"description,omitempty"`
+			Datasource  datasource `json:"datasource"`
+			GridPos     gridPos    `json:"gridPos"`
+			FieldConfig any        `json:"fieldConfig"`
+			Targets     []target   `json:"targets"`
+		}
+		variable struct {
+			Name       string     `json:"name"`
+			Label      string     `json:"label"`
+			Type       string     `json:"type"`
+			Datasource datasource `json:"datasource"`
+			Query      string     `json:"query"`
+			Refresh    int        `json:"refresh"`
+			IncludeAll bool       `json:"includeAll"`
+			AllValue   string     `json:"allValue"`
+			Multi      bool       `json:"multi"`
+			Sort       int        `json:"sort"`
+		}
+	)
+
+	prometheus := datasource{Type: "prometheus", UID: datasourceUID}
+
+	variables := make([]variable, len(d.Variables))
+	for i, v := range d.Variables {
+		variables[i] = variable{
+			Name:       v.Name,
+			Label:      v.Label,
+			Type:       "query",
+			Datasource: prometheus,
+			Query:      "label_values(" + v.Metric + ", " + v.Label + ")",
+			Refresh:    2,
+			IncludeAll: true,
+			AllValue:   ".*",
+			Multi:      true,
+			Sort:       1,
+		}
+	}
+
+	// Panels are laid out two to a row
+	const width, height = 12, 8
+	panels := make([]panel, len(d.Panels))
+	for i, p := range d.Panels {
+		targets := make([]target, len(p.Targets))
+		for j, t := range p.Targets {
+			targets[j] = target{
+				RefID:        string(rune('A' + j)),
+				Datasource:   prometheus,
+				Expr:         t.Expr,
+				LegendFormat: t.Legend,
+				Exemplar:     t.Exemplar,
+			}
+		}
+
+		panels[i] = panel{
+			ID:          i + 1,
+			Type:        "timeseries",
+			Title:       p.Title,
+			Description: p.Description,
+			Datasource:  prometheus,
+			GridPos:     gridPos{H: height, W: width, X: (i % 2) * width, Y: (i / 2) * height},
+			FieldConfig: map[string]any{
+				"defaults":  map[string]any{"unit": p.Unit},
+				"overrides": []any{},
+			},
+			Targets: targets,
+		}
+	}
+
+	// PromQL comparisons are kept legible rather than escaped as HTML
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(map[string]any{
+		"uid":           d.UID,
+		"title":         d.Title,
+		"description":   d.Description,
+		"version":       d.Version,
+		"tags":          []string{"go-chi-api"},
+		"editable":      false,
+		"schemaVersion": 39,
+		"time":          map[string]string{"from": "now-1h", "to": "now"},
+		"refresh":       "30s",
+		"templating":    map[string]any{"list": variables},
+		"panels":        panels,
+	})
+
+	return b.Bytes(), err
+}
+
+// Returns the contents of every generated file, keyed by its path relative to
+// the o11y directory
+func Files() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, dashboard := range Dashboards() {
+		b, err := dashboard.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, b, "", "  "); err != nil {
+			return nil, err
+		}
+
+		files[path.Join("dashboards", dashboard.UID+".json")] = indented.Bytes()
+	}
+
+	rules, err := marshalRules(RuleGroups())
+	if err != nil {
+		return nil, err
+	}
+	files["prometheus-rules.yaml"] = rules
+
+	return files, nil
+}
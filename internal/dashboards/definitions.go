@@ -0,0 +1,278 @@
+package dashboards
+
+import "fmt"
+
+// Selects the series of the routes chosen by a dashboard's route variable
+const routeMatcher = `http_route=~"$route"`
+
+func Dashboards() []Dashboard {
+	return []Dashboard{
+		httpDashboard(),
+		databaseDashboard(),
+		authDashboard(),
+		runtimeDashboard(),
+	}
+}
+
+func httpDashboard() Dashboard {
+	return Dashboard{
+		UID:         "go-chi-api-http",
+		Title:       "go-chi-api / HTTP",
+		Version:     1,
+		Description: "Request rate, errors and duration of the API, by chi route",
+		Variables: []Variable{
+			{Name: "route", Label: "http_route", Metric: httpRequestDuration.Count()},
+		},
+		Panels: []Panel{
+			{
+				Title: "Requests",
+				Unit:  "reqps",
+				Targets: []Target{{
+					Expr:   fmt.Sprintf(`sum by (http_request_method, http_route) (rate(%s{%s}[$__rate_interval]))`, httpRequestDuration.Count(), routeMatcher),
+					Legend: "{{http_request_method}} {{http_route}}",
+				}},
+			},
+			{
+				Title:       "Errors",
+				Description: "Share of requests answered with a 5xx status",
+				Unit:        "percentunit",
+				Targets: []Target{{
+					Expr: fmt.Sprintf(
+						`sum by (http_route) (rate(%[1]s{%[2]s, http_response_status_code=~"5.."}[$__rate_interval])) / sum by (http_route) (rate(%[1]s{%[2]s}[$__rate_interval]))`,
+						httpRequestDuration.Count(), routeMatcher,
+					),
+					Legend: "{{http_route}}",
+				}},
+			},
+			{
+				Title: "Duration p95",
+				Unit:  "s",
+				Targets: []Target{{
+					Expr:     fmt.Sprintf(`histogram_quantile(0.95, sum by (le, http_route) (rate(%s{%s}[$__rate_interval])))`, httpRequestDuration.Bucket(), routeMatcher),
+					Legend:   "{{http_route}}",
+					Exemplar: true,
+				}},
+			},
+			{
+				Title: "Duration p50 and p99",
+				Unit:  "s",
+				Targets: []Target{
+					{
+						Expr:   fmt.Sprintf(`histogram_quantile(0.5, sum by (le) (rate(%s{%s}[$__rate_interval])))`, httpRequestDuration.Bucket(), routeMatcher),
+						Legend: "p50",
+					},
+					{
+						Expr:     fmt.Sprintf(`histogram_quantile(0.99, sum by (le) (rate(%s{%s}[$__rate_interval])))`, httpRequestDuration.Bucket(), routeMatcher),
+						Legend:   "p99",
+						Exemplar: true,
+					},
+				},
+			},
+			{
+				Title: "Responses by status",
+				Unit:  "reqps",
+				Targets: []Target{{
+					Expr:   fmt.Sprintf(`sum by (http_response_status_code) (rate(%s{%s}[$__rate_interval]))`, httpRequestDuration.Count(), routeMatcher),
+					Legend: "{{http_response_status_code}}",
+				}},
+			},
+			{
+				Title: "Active requests",
+				Unit:  "short",
+				Targets: []Target{{
+					Expr:   fmt.Sprintf(`sum by (http_request_method) (%s)`, httpActiveRequests),
+					Legend: "{{http_request_method}}",
+				}},
+			},
+			{
+				Title: "Request body size p95",
+				Unit:  "bytes",
+				Targets: []Target{{
+					Expr:   fmt.Sprintf(`histogram_quantile(0.95, sum by (le, http_route) (rate(%s{%s}[$__rate_interval])))`, httpRequestSize.Bucket(), routeMatcher),
+					Legend: "{{http_route}}",
+				}},
+			},
+			{
+				Title: "Response body size p95",
+				Unit:  "bytes",
+				Targets: []Target{{
+					Expr:   fmt.Sprintf(`histogram_quantile(0.95, sum by (le, http_route) (rate(%s{%s}[$__rate_interval])))`, httpResponseSize.Bucket(), routeMatcher),
+					Legend: "{{http_route}}",
+				}},
+			},
+		},
+	}
+}
+
+func databaseDashboard() Dashboard {
+	return Dashboard{
+		UID:         "go-chi-api-database",
+		Title:       "go-chi-api / Database pool",
+		Version:     1,
+		Description: "Connections held and waited for by the database connection pool",
+		Panels: []Panel{
+			{
+				Title: "Connections",
+				Unit:  "short",
+				Targets: []Target{
+					{
+						Expr:   fmt.Sprintf(`sum by (db_client_connection_state) (%s)`, dbConnectionCount),
+						Legend: "{{db_client_connection_state}}",
+					},
+					{
+						Expr:   fmt.Sprintf(`sum(%s)`, dbConnectionMax),
+						Legend: "max",
+					},
+				},
+			},
+			{
+				Title:       "Utilization",
+				Description: "Share of the connections allowed which are in use, when the pool is limited",
+				Unit:        "percentunit",
+				Targets: []Target{{
+					Expr: fmt.Sprintf(
+						`sum by (db_client_connection_pool_name) (%s{db_client_connection_state="used"}) / sum by (db_client_connection_pool_name) (%s > 0)`,
+						dbConnectionCount, dbConnectionMax,
+					),
+					Legend: "{{db_client_connection_pool_name}}",
+				}},
+			},
+			{
+				Title: "Waits",
+				Unit:  "ops",
+				Targets: []Target{{
+					Expr:   fmt.Sprintf(`sum by (db_client_connection_pool_name) (rate(%s[$__rate_interval]))`, dbConnectionWaits),
+					Legend: "{{db_client_connection_pool_name}}",
+				}},
+			},
+			{
+				Title:       "Average wait",
+				Description: "Time each wait for a connection took on average",
+				Unit:        "s",
+				Targets: []Target{{
+					Expr: fmt.Sprintf(
+						`sum by (db_client_connection_pool_name) (rate(%s[$__rate_interval])) / sum by (db_client_connection_pool_name) (rate(%s[$__rate_interval]))`,
+						dbConnectionWaitDuration, dbConnectionWaits,
+					),
+					Legend: "{{db_client_connection_pool_name}}",
+				}},
+			},
+		},
+	}
+}
+
+func authDashboard() Dashboard {
+	return Dashboard{
+		UID:         "go-chi-api-auth",
+		Title:       "go-chi-api / Authentication",
+		Version:     1,
+		Description: "Registrations, logins, rejected tokens and password hashing",
+		Panels: []Panel{
+			{
+				Title: "Logins",
+				Unit:  "ops",
+				Targets: []Target{{
+					Expr:   fmt.Sprintf(`sum by (result, reason) (rate(%s[$__rate_interval]))`, authLogins),
+					Legend: "{{result}} {{reason}}",
+				}},
+			},
+			{
+				Title:       "Login failures",
+				Description: "Share of login attempts which failed",
+				Unit:        "percentunit",
+				Targets: []Target{{
+					Expr: fmt.Sprintf(
+						`sum(rate(%[1]s{result="failure"}[$__rate_interval])) / sum(rate(%[1]s[$__rate_interval]))`,
+						authLogins,
+					),
+					Legend: "failures",
+				}},
+			},
+			{
+				Title: "Registrations",
+				Unit:  "ops",
+				Targets: []Target{{
+					Expr:   fmt.Sprintf(`sum by (result, reason) (rate(%s[$__rate_interval]))`, authRegistrations),
+					Legend: "{{result}} {{reason}}",
+				}},
+			},
+			{
+				Title: "Rejected tokens",
+				Unit:  "reqps",
+				Targets: []Target{{
+					Expr:   fmt.Sprintf(`sum by (reason) (rate(%s[$__rate_interval]))`, authTokenValidationFailures),
+					Legend: "{{reason}}",
+				}},
+			},
+			{
+				Title: "Password hashing p95",
+				Unit:  "s",
+				Targets: []Target{{
+					Expr:     fmt.Sprintf(`histogram_quantile(0.95, sum by (le, operation) (rate(%s[$__rate_interval])))`, authPasswordHashDuration.Bucket()),
+					Legend:   "{{operation}}",
+					Exemplar: true,
+				}},
+			},
+		},
+	}
+}
+
+func runtimeDashboard() Dashboard {
+	return Dashboard{
+		UID:         "go-chi-api-runtime",
+		Title:       "go-chi-api / Go runtime",
+		Version:     1,
+		Description: "Memory, garbage collection and goroutines of each instance",
+		Panels: []Panel{
+			{
+				Title: "Goroutines",
+				Unit:  "short",
+				Targets: []Target{{
+					Expr:   goGoroutineCount.String(),
+					Legend: "{{instance}}",
+				}},
+			},
+			{
+				Title: "Memory used",
+				Unit:  "bytes",
+				Targets: []Target{{
+					Expr:   fmt.Sprintf(`sum by (instance, go_memory_type) (%s)`, goMemoryUsed),
+					Legend: "{{instance}} {{go_memory_type}}",
+				}},
+			},
+			{
+				Title:       "Heap goal",
+				Description: "Heap size the garbage collector aims to keep below, which GOGC sets",
+				Unit:        "bytes",
+				Targets: []Target{{
+					Expr:   goMemoryGCGoal.String(),
+					Legend: "{{instance}}",
+				}},
+			},
+			{
+				Title: "GOGC",
+				Unit:  "percent",
+				Targets: []Target{{
+					Expr:   goConfigGogc.String(),
+					Legend: "{{instance}}",
+				}},
+			},
+			{
+				Title: "Allocation rate",
+				Unit:  "Bps",
+				Targets: []Target{{
+					Expr:   fmt.Sprintf(`rate(%s[$__rate_interval])`, goMemoryAllocated),
+					Legend: "{{instance}}",
+				}},
+			},
+			{
+				Title: "Allocations",
+				Unit:  "ops",
+				Targets: []Target{{
+					Expr:   fmt.Sprintf(`rate(%s[$__rate_interval])`, goMemoryAllocations),
+					Legend: "{{instance}}",
+				}},
+			},
+		},
+	}
+}
@@ -0,0 +1,83 @@
+package dashboards
+
+import (
+	telemetry "go-chi-api/internal/otel"
+
+	"github.com/prometheus/otlptranslator"
+)
+
+// A metric emitted by the service. Its name in queries is the name Prometheus
+// gives it, whether scraped or received from the collector, which depends on
+// the unit and type as well as the OpenTelemetry name
+type Metric struct {
+	Name string
+	Unit string
+	Type otlptranslator.MetricType
+}
+
+var namer = otlptranslator.MetricNamer{WithMetricSuffixes: true}
+
+var (
+	httpRequestDuration = histogram(telemetry.MetricHTTPServerRequestDuration, "s")
+	httpActiveRequests  = upDownCounter(telemetry.MetricHTTPServerActiveRequests, "{request}")
+	httpRequestSize     = histogram(telemetry.MetricHTTPServerRequestSize, "By")
+	httpResponseSize    = histogram(telemetry.MetricHTTPServerResponseSize, "By")
+
+	dbConnectionCount        = upDownCounter(telemetry.MetricDBClientConnectionCount, "{connection}")
+	dbConnectionMax          = upDownCounter(telemetry.MetricDBClientConnectionMax, "{connection}")
+	dbConnectionWaits        = counter(telemetry.MetricDBClientConnectionWaits, "{wait}")
+	dbConnectionWaitDuration = counter(telemetry.MetricDBClientConnectionWaitDuration, "s")
+
+	authRegistrations           = counter(telemetry.MetricAuthRegistrations, "{registration}")
+	authLogins                  = counter(telemetry.MetricAuthLogins, "{login}")
+	authTokenValidationFailures = counter(telemetry.MetricAuthTokenValidationFailures, "{request}")
+	authPasswordHashDuration    = histogram(telemetry.MetricAuthPasswordHashDuration, "s")
+
+	goMemoryUsed        = upDownCounter(telemetry.MetricGoMemoryUsed, "By")
+	goMemoryAllocated   = counter(telemetry.MetricGoMemoryAllocated, "By")
+	goMemoryAllocations = counter(telemetry.MetricGoMemoryAllocations, "{allocation}")
+	goMemoryGCGoal      = upDownCounter(telemetry.MetricGoMemoryGCGoal, "By")
+	goGoroutineCount    = upDownCounter(telemetry.MetricGoGoroutineCount, "{goroutine}")
+	goConfigGogc        = upDownCounter(telemetry.MetricGoConfigGogc, "%")
+)
+
+func counter(name string, unit string) Metric {
+	return Metric{Name: name, Unit: unit, Type: otlptranslator.MetricTypeMonotonicCounter}
+}
+
+func upDownCounter(name string, unit string) Metric {
+	return Metric{Name: name, Unit: unit, Type: otlptranslator.MetricTypeNonMonotonicCounter}
+}
+
+func histogram(name string, unit string) Metric {
+	return Metric{Name: name, Unit: unit, Type: otlptranslator.MetricTypeHistogram}
+}
+
+// Returns the metric's name in Prometheus
+func PrometheusName(m Metric) string {
+	name, err := namer.Build(otlptranslator.Metric{Name: m.Name, Unit: m.Unit, Type: m.Type})
+	if err != nil {
+		panic(err)
+	}
+
+	return name
+}
+
+func (m Metric) String() string {
+	return PrometheusName(m)
+}
+
+// Returns the name of the series holding a histogram's bucket counts
+func (m Metric) Bucket() string {
+	return PrometheusName(m) + "_bucket"
+}
+
+// Returns the name of the series counting a histogram's observations
+func (m Metric) Count() string {
+	return PrometheusName(m) + "_count"
+}
+
+// Returns the name of the series summing a histogram's observations
+func (m Metric) Sum() string {
+	return PrometheusName(m) + "_sum"
+}
@@ -0,0 +1,166 @@
+package dashboards
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	RuleGroup struct {
+		Name  string `yaml:"name"`
+		Rules []Rule `yaml:"rules"`
+	}
+
+	// Either a recording rule, storing Expr as the series Record, or an
+	// alerting rule firing once Expr has held for For
+	Rule struct {
+		Record      string            `yaml:"record,omitempty"`
+		Alert       string            `yaml:"alert,omitempty"`
+		Expr        string            `yaml:"expr"`
+		For         string            `yaml:"for,omitempty"`
+		Labels      map[string]string `yaml:"labels,omitempty"`
+		Annotations map[string]string `yaml:"annotations,omitempty"`
+	}
+)
+
+// Series stored by recording rules, which dashboards and alerts may use as if
+// they were emitted by the service
+var (
+	recordRouteRequestRate = "http_route:" + httpRequestDuration.Count() + ":rate5m"
+	recordRouteErrorRate   = "http_route:" + httpRequestDuration.Count() + ":errors_rate5m"
+	recordRouteLatencyP95  = "http_route:" + httpRequestDuration.String() + ":p95_5m"
+	recordLoginFailureRate = "reason:" + authLogins.String() + ":failures_rate5m"
+)
+
+func RuleGroups() []RuleGroup {
+	return []RuleGroup{
+		{
+			Name: "go-chi-api-http",
+			Rules: []Rule{
+				{
+					Record: recordRouteRequestRate,
+					Expr:   fmt.Sprintf(`sum by (http_route) (rate(%s[5m]))`, httpRequestDuration.Count()),
+				},
+				{
+					Record: recordRouteErrorRate,
+					Expr:   fmt.Sprintf(`sum by (http_route) (rate(%s{http_response_status_code=~"5.."}[5m]))`, httpRequestDuration.Count()),
+				},
+				{
+					Record: recordRouteLatencyP95,
+					Expr:   fmt.Sprintf(`histogram_quantile(0.95, sum by (le, http_route) (rate(%s[5m])))`, httpRequestDuration.Bucket()),
+				},
+				{
+					Alert: "APIHighErrorRate",
+					Expr:  fmt.Sprintf(`sum(%s) / sum(%s) > 0.05`, recordRouteErrorRate, recordRouteRequestRate),
+					For:   "5m",
+					Labels: map[string]string{
+						"severity": "page",
+					},
+					Annotations: map[string]string{
+						"summary":     "More than 5% of API requests are failing",
+						"description": "{{ $value | humanizePercentage }} of requests have returned a 5xx response over the last 5 minutes",
+					},
+				},
+				{
+					Alert: "APIHighLatency",
+					Expr:  fmt.Sprintf(`%s > 1`, recordRouteLatencyP95),
+					For:   "10m",
+					Labels: map[string]string{
+						"severity": "warning",
+					},
+					Annotations: map[string]string{
+						"summary":     "{{ $labels.http_route }} is slow",
+						"description": "The 95th percentile latency of {{ $labels.http_route }} is {{ $value | humanizeDuration }}",
+					},
+				},
+			},
+		},
+		{
+			Name: "go-chi-api-database",
+			Rules: []Rule{
+				{
+					Alert: "DatabasePoolNearlyExhausted",
+					Expr: fmt.Sprintf(
+						`sum by (db_client_connection_pool_name) (%s{db_client_connection_state="used"}) / sum by (db_client_connection_pool_name) (%s > 0) > 0.9`,
+						dbConnectionCount, dbConnectionMax,
+					),
+					For: "5m",
+					Labels: map[string]string{
+						"severity": "page",
+					},
+					Annotations: map[string]string{
+						"summary":     "Database connection pool is nearly exhausted",
+						"description": "{{ $value | humanizePercentage }} of the connections allowed by {{ $labels.db_client_connection_pool_name }} are in use",
+					},
+				},
+				{
+					Alert: "DatabasePoolWaits",
+					Expr:  fmt.Sprintf(`sum by (db_client_connection_pool_name) (rate(%s[5m])) > 0.1`, dbConnectionWaitDuration),
+					For:   "5m",
+					Labels: map[string]string{
+						"severity": "warning",
+					},
+					Annotations: map[string]string{
+						"summary":     "Requests are waiting for database connections",
+						"description": "Queries spent {{ $value | humanizeDuration }} per second waiting for a connection from {{ $labels.db_client_connection_pool_name }}",
+					},
+				},
+			},
+		},
+		{
+			Name: "go-chi-api-auth",
+			Rules: []Rule{
+				{
+					Record: recordLoginFailureRate,
+					Expr:   fmt.Sprintf(`sum by (reason) (rate(%s{result="failure"}[5m]))`, authLogins),
+				},
+				{
+					Alert: "CredentialStuffing",
+					Expr: fmt.Sprintf(
+						`sum(%s) / sum(rate(%s[5m])) > 0.5 and sum(%s) > 1`,
+						recordLoginFailureRate, authLogins, recordLoginFailureRate,
+					),
+					For: "10m",
+					Labels: map[string]string{
+						"severity": "page",
+					},
+					Annotations: map[string]string{
+						"summary":     "Most login attempts are failing",
+						"description": "{{ $value | humanizePercentage }} of logins have failed over the last 5 minutes, which may indicate credential stuffing",
+					},
+				},
+				{
+					Alert: "TokenValidationFailures",
+					Expr:  fmt.Sprintf(`sum by (reason) (rate(%s{reason!="missing"}[5m])) > 1`, authTokenValidationFailures),
+					For:   "10m",
+					Labels: map[string]string{
+						"severity": "warning",
+					},
+					Annotations: map[string]string{
+						"summary":     "Requests are presenting invalid tokens",
+						"description": "{{ $value | humanize }} requests per second were rejected with {{ $labels.reason }} tokens",
+					},
+				},
+			},
+		},
+	}
+}
+
+// Returns the rule groups as a Prometheus rules file
+func marshalRules(groups []RuleGroup) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("# Generated by go run ./cmd/o11y from internal/dashboards. DO NOT EDIT.\n")
+
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string][]RuleGroup{"groups": groups}); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
@@ -3,68 +3,93 @@ package database
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"errors"
+	"go-chi-api/internal/config"
 	"go-chi-api/internal/domain"
-	"log"
-	"os"
-	"time"
+	"go-chi-api/internal/health"
+	"go-chi-api/internal/idempotency"
+	"go-chi-api/internal/ratelimit"
+	"net"
+	"net/url"
+	"strconv"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
-	_ "github.com/joho/godotenv/autoload"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 )
 
 type Service interface {
-	Health() (bool, string)
+	Health(ctx context.Context) health.Result
 	GetUserById(ctx context.Context, id int64) (*domain.User, error)
 	GetUserByUsername(ctx context.Context, username string) (*domain.User, error)
 
 	// Creates a user in the database, filling the Id field of the user on
 	// success, or returning an error if the email is not unique
 	CreateUser(ctx context.Context, user *domain.User) error
-}
 
-type service struct {
-	db *sql.DB
+	// Returns a store of idempotency records shared by every instance
+	IdempotencyStore() idempotency.Store
+
+	// Returns a store of rate limit states shared by every instance
+	RateLimitStore() ratelimit.Store
+
+	// Closes the database, releasing its connections
+	Close() error
 }
 
-var (
-	database = os.Getenv("DB_DATABASE")
-	password = os.Getenv("DB_PASSWORD")
-	username = os.Getenv("DB_USERNAME")
-	port     = os.Getenv("DB_PORT")
-	host     = os.Getenv("DB_HOST")
-)
+type service struct {
+	db         *sql.DB
+	thresholds config.DatabaseHealth
 
-const (
-	HealthyMessage   = "Healthy"
-	UnhealthyMessage = "Unhealthy"
-)
+	// Attributes describing the database on every span, and the connection
+	// pool on every pool metric
+	attrs     []attribute.KeyValue
+	poolAttrs []attribute.KeyValue
+}
 
-func New() Service {
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", username, password, host, port, database)
-	db, err := sql.Open("pgx", connStr)
+func New(cfg config.Database) (Service, error) {
+	connStr := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.Username, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Path:     cfg.Name,
+		RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+	}
+	db, err := sql.Open("pgx", connStr.String())
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	s := &service{db: db}
-	return s
-}
-
-func (s *service) Health() (bool, string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-	defer cancel()
+	db.SetMaxOpenConns(cfg.MaxOpenConnections)
+
+	s := &service{
+		db:         db,
+		thresholds: cfg.Health,
+		attrs: []attribute.KeyValue{
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBNamespace(cfg.Name),
+			semconv.ServerAddress(cfg.Host),
+			semconv.ServerPort(cfg.Port),
+		},
+		poolAttrs: []attribute.KeyValue{
+			semconv.DBClientConnectionPoolName(connStr.Host + "/" + cfg.Name),
+		},
+	}
 
-	err := s.db.PingContext(ctx)
-	if err != nil {
-		return false, UnhealthyMessage
+	if err := s.registerPoolMetrics(); err != nil {
+		return nil, errors.Join(err, db.Close())
 	}
 
-	return true, HealthyMessage
+	return s, nil
+}
+
+func (s *service) Close() error {
+	return s.db.Close()
 }
 
 func (s *service) GetUserByUsername(ctx context.Context, username string) (*domain.User, error) {
-	row := s.db.QueryRowContext(ctx, `
+	var user domain.User
+	err := s.queryRow(ctx, scanUser(&user), `
 SELECT id, username, status, password_hash, created_at, updated_at, deleted_at
 FROM goapi.users
 WHERE username = $1
@@ -72,48 +97,16 @@ LIMIT 1`,
 		username,
 	)
 
-	var user domain.User
-	var updatedAt sql.NullTime
-	var deletedAt sql.NullTime
-	var status string
-	err := row.Scan(
-		&user.Id,
-		&user.Username,
-		&status,
-		&user.PasswordHash,
-		&user.CreatedAtTimestamp,
-		&updatedAt,
-		&deletedAt,
-	)
-
 	if err != nil {
 		return nil, err
 	}
 
-	switch status {
-	case domain.ActiveStr:
-		user.Status = domain.Active
-	case domain.DeletedStr:
-		user.Status = domain.Deleted
-	}
-
-	if updatedAt.Valid {
-		user.UpdatedAtTimestamp = &updatedAt.Time
-	} else {
-		user.UpdatedAtTimestamp = nil
-	}
-
-	if deletedAt.Valid {
-		user.DeletedAtTimestamp = &deletedAt.Time
-	} else {
-		user.DeletedAtTimestamp = nil
-	}
-
 	return &user, nil
 }
 
 func (s *service) GetUserById(ctx context.Context, id int64) (*domain.User, error) {
-	row := s.db.QueryRowContext(ctx, `
+	var user domain.User
+	err := s.queryRow(ctx, scanUser(&user), `
 SELECT id, username, status, password_hash, created_at, updated_at, deleted_at
 FROM goapi.users
 WHERE id = $1
@@ -121,48 +114,19 @@ LIMIT 1`,
 		id,
 	)
 
-	var user domain.User
-	var updatedAt sql.NullTime
-	var deletedAt sql.NullTime
-	var status string
-	err := row.Scan(
-		&user.Id,
-		&user.Username,
-		&status,
-		&user.PasswordHash,
-		&user.CreatedAtTimestamp,
-		&updatedAt,
-		&deletedAt,
-	)
-
 	if err != nil {
 		return nil, err
 	}
 
-	switch status {
-	case domain.ActiveStr:
-		user.Status = domain.Active
-	case domain.DeletedStr:
-		user.Status = domain.Deleted
-	}
-
-	if updatedAt.Valid {
-		user.UpdatedAtTimestamp = &updatedAt.Time
-	} else {
-		user.UpdatedAtTimestamp = nil
-	}
-
-	if deletedAt.Valid {
-		user.DeletedAtTimestamp = &deletedAt.Time
-	} else {
-		user.DeletedAtTimestamp = nil
-	}
-
 	return &user, nil
 }
 
 func (s *service) CreateUser(ctx context.Context, user *domain.User) error {
-	row := s.db.QueryRowContext(ctx, `
+	scanId := func(row *sql.Row) error {
+		return row.Scan(&user.Id)
+	}
+
+	return s.queryRow(ctx, scanId, `
 INSERT INTO goapi.users (username, status, password_hash, created_at)
 VALUES ($1, $2, $3, $4)
 RETURNING id`,
@@ -171,7 +135,47 @@ RETURNING id`,
 		user.PasswordHash,
 		user.CreatedAtTimestamp,
 	)
+}
 
-	err := row.Scan(&user.Id)
-	return err
+// Scans a row of goapi.users into user
+func scanUser(user *domain.User) func(*sql.Row) error {
+	return func(row *sql.Row) error {
+		var updatedAt sql.NullTime
+		var deletedAt sql.NullTime
+		var status string
+		err := row.Scan(
+			&user.Id,
+			&user.Username,
+			&status,
+			&user.PasswordHash,
+			&user.CreatedAtTimestamp,
+			&updatedAt,
+			&deletedAt,
+		)
+
+		if err != nil {
+			return err
+		}
+
+		switch status {
+		case domain.ActiveStr:
+			user.Status = domain.Active
+		case domain.DeletedStr:
+			user.Status = domain.Deleted
+		}
+
+		if updatedAt.Valid {
+			user.UpdatedAtTimestamp = &updatedAt.Time
+		} else {
+			user.UpdatedAtTimestamp = nil
+		}
+
+		if deletedAt.Valid {
+			user.DeletedAtTimestamp = &deletedAt.Time
+		} else {
+			user.DeletedAtTimestamp = nil
+		}
+
+		return nil
+	}
 }
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go-chi-api/internal/health"
+	"go-chi-api/migrations"
+)
+
+const HealthyMessage = "Healthy"
+
+// Pings the database, then reports its latency, the state of the connection
+// pool, the server's version and role, and any pending migrations. The
+// database is degraded when any of these cross the configured thresholds, or
+// cannot be determined
+func (s *service) Health(ctx context.Context) health.Result {
+	start := time.Now()
+	if err := s.ping(ctx); err != nil {
+		return health.Result{
+			Status:      health.Unhealthy,
+			Description: "Failed to ping database: " + err.Error(),
+			Data:        s.poolData(),
+		}
+	}
+	latency := time.Since(start)
+
+	data := s.poolData()
+	data["latency"] = latency.String()
+
+	var problems []string
+	if latency > s.thresholds.MaxLatency {
+		problems = append(problems, fmt.Sprintf("Latency of %s exceeds %s", latency, s.thresholds.MaxLatency))
+	}
+
+	stats := s.db.Stats()
+	if stats.MaxOpenConnections > 0 {
+		utilization := float64(stats.InUse) / float64(stats.MaxOpenConnections)
+		data["pool_utilization"] = utilization
+		if utilization > s.thresholds.MaxPoolUtilization {
+			problems = append(problems, fmt.Sprintf("%.0f%% of connections are in use", utilization*100))
+		}
+	}
+
+	problems = append(problems, s.serverHealth(ctx, data)...)
+	problems = append(problems, s.migrationHealth(ctx, data)...)
+
+	if len(problems) > 0 {
+		return health.Result{Status: health.Degraded, Description: strings.Join(problems, "; "), Data: data}
+	}
+
+	return health.Result{Status: health.Healthy, Description: HealthyMessage, Data: data}
+}
+
+func (s *service) poolData() map[string]any {
+	stats := s.db.Stats()
+	return map[string]any{
+		"open_connections":     stats.OpenConnections,
+		"in_use":               stats.InUse,
+		"idle":                 stats.Idle,
+		"max_open_connections": stats.MaxOpenConnections,
+		"wait_count":           stats.WaitCount,
+		"wait_duration":        stats.WaitDuration.String(),
+	}
+}
+
+// Adds the server's version, whether it is a primary or replica, and how far
+// a replica lags behind its primary to data, returning any problems found
+func (s *service) serverHealth(ctx context.Context, data map[string]any) []string {
+	var version string
+	var replica bool
+	var lag sql.NullFloat64
+	scan := func(row *sql.Row) error {
+		return row.Scan(&version, &replica, &lag)
+	}
+
+	err := s.queryRow(ctx, scan, `
+SELECT current_setting('server_version'),
+	pg_is_in_recovery(),
+	EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())::float8`)
+	if err != nil {
+		return []string{"Failed to query server status: " + err.Error()}
+	}
+
+	data["version"] = version
+	if !replica {
+		data["role"] = "primary"
+		return nil
+	}
+
+	data["role"] = "replica"
+	if !lag.Valid {
+		return []string{"Replica has not replayed any transactions"}
+	}
+
+	replicationLag := time.Duration(lag.Float64 * float64(time.Second))
+	data["replication_lag"] = replicationLag.String()
+	if replicationLag > s.thresholds.MaxReplicationLag {
+		return []string{fmt.Sprintf("Replication lag of %s exceeds %s", replicationLag, s.thresholds.MaxReplicationLag)}
+	}
+
+	return nil
+}
+
+// Adds the versions of migrations which have not been applied to data,
+// returning a problem if there are any
+func (s *service) migrationHealth(ctx context.Context, data map[string]any) []string {
+	versions, err := migrations.Versions()
+	if err != nil {
+		return []string{"Failed to read migrations: " + err.Error()}
+	}
+
+	// Older databases predate goapi.schema_migrations, in which case no
+	// migrations are recorded as applied
+	var recorded bool
+	err = s.queryRow(ctx, func(row *sql.Row) error { return row.Scan(&recorded) },
+		`SELECT to_regclass('goapi.schema_migrations') IS NOT NULL`)
+	if err != nil {
+		return []string{"Failed to query applied migrations: " + err.Error()}
+	}
+
+	var applied sql.NullString
+	if recorded {
+		err = s.queryRow(ctx, func(row *sql.Row) error { return row.Scan(&applied) },
+			`SELECT string_agg(version, ',') FROM goapi.schema_migrations`)
+		if err != nil {
+			return []string{"Failed to query applied migrations: " + err.Error()}
+		}
+	}
+
+	appliedVersions := strings.Split(applied.String, ",")
+	pending := []string{}
+	for _, version := range versions {
+		if !slices.Contains(appliedVersions, version) {
+			pending = append(pending, version)
+		}
+	}
+
+	data["pending_migrations"] = pending
+	if len(pending) > 0 {
+		return []string{fmt.Sprintf("%d migrations are pending", len(pending))}
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go-chi-api/internal/idempotency"
+)
+
+// Stores idempotency records in goapi.idempotency_keys, so every instance
+// replays the same responses
+type idempotencyStore struct {
+	s *service
+}
+
+func (s *service) IdempotencyStore() idempotency.Store {
+	return &idempotencyStore{s: s}
+}
+
+func (i *idempotencyStore) Claim(ctx context.Context, key string, fingerprint string, now time.Time, expiresAt time.Time) (*idempotency.Record, error) {
+	// The record may expire or be released between the two queries, in which
+	// case claiming it again succeeds
+	for range 2 {
+		claimed := false
+		scanClaimed := func(row *sql.Row) error {
+			return row.Scan(&claimed)
+		}
+
+		// A few other expired records are removed as each key is claimed,
+		// skipping any being claimed concurrently, and an expired record for
+		// this key is replaced
+		err := i.s.queryRow(ctx, scanClaimed, `
+WITH expired AS (
+    DELETE FROM goapi.idempotency_keys
+    WHERE key IN (
+        SELECT key FROM goapi.idempotency_keys
+        WHERE expires_at <= $3 AND key <> $1
+        LIMIT 10
+        FOR UPDATE SKIP LOCKED
+    )
+)
+INSERT INTO goapi.idempotency_keys (key, fingerprint, expires_at)
+VALUES ($1, $2, $4)
+ON CONFLICT (key) DO UPDATE
+SET fingerprint = EXCLUDED.fingerprint, status = NULL, headers = NULL, body = NULL, expires_at = EXCLUDED.expires_at
+WHERE goapi.idempotency_keys.expires_at <= $3
+RETURNING true`,
+			key,
+			fingerprint,
+			now,
+			expiresAt,
+		)
+
+		switch {
+		case err == nil:
+			return nil, nil
+		case !errors.Is(err, sql.ErrNoRows):
+			return nil, err
+		}
+
+		record, err := i.get(ctx, key)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+
+		return record, err
+	}
+
+	return nil, errors.New("Idempotency key changed while being claimed")
+}
+
+func (i *idempotencyStore) get(ctx context.Context, key string) (*idempotency.Record, error) {
+	var record idempotency.Record
+	scanRecord := func(row *sql.Row) error {
+		var status sql.NullInt32
+		var headers []byte
+		var body []byte
+		if err := row.Scan(&record.Fingerprint, &status, &headers, &body, &record.ExpiresAt); err != nil {
+			return err
+		}
+
+		if !status.Valid {
+			return nil
+		}
+
+		record.Response = &idempotency.Response{Status: int(status.Int32), Body: body}
+		return json.Unmarshal(headers, &record.Response.Header)
+	}
+
+	err := i.s.queryRow(ctx, scanRecord, `
+SELECT fingerprint, status, headers, body, expires_at
+FROM goapi.idempotency_keys
+WHERE key = $1`,
+		key,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (i *idempotencyStore) Complete(ctx context.Context, key string, response idempotency.Response) error {
+	headers, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+
+	err = i.s.queryRow(ctx, scanNothing, `
+UPDATE goapi.idempotency_keys
+SET status = $2, headers = $3, body = $4
+WHERE key = $1
+RETURNING key`,
+		key,
+		response.Status,
+		headers,
+		response.Body,
+	)
+
+	// The record expired while the request was handled
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	return err
+}
+
+func (i *idempotencyStore) Release(ctx context.Context, key string) error {
+	err := i.s.queryRow(ctx, scanNothing, `
+DELETE FROM goapi.idempotency_keys
+WHERE key = $1
+RETURNING key`,
+		key,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	return err
+}
+
+// Scans a row without keeping any of it, for statements run for their effect
+func scanNothing(row *sql.Row) error {
+	var discard any
+	return row.Scan(&discard)
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-chi-api/internal/ratelimit"
+)
+
+// Stores rate limit states in goapi.rate_limits, so requests are limited
+// across every instance
+type rateLimitStore struct {
+	s *service
+}
+
+func (s *service) RateLimitStore() ratelimit.Store {
+	return &rateLimitStore{s: s}
+}
+
+func (l *rateLimitStore) Get(ctx context.Context, key string, now time.Time) (ratelimit.State, error) {
+	var state ratelimit.State
+	scanState := func(row *sql.Row) error {
+		return row.Scan(&state.Value, &state.Previous, &state.Time, &state.Version)
+	}
+
+	err := l.s.queryRow(ctx, scanState, `
+SELECT value, previous, time, version
+FROM goapi.rate_limits
+WHERE key = $1 AND expires_at > $2`,
+		key,
+		now,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ratelimit.State{}, nil
+	}
+
+	return state, err
+}
+
+func (l *rateLimitStore) CompareAndSwap(ctx context.Context, key string, previous ratelimit.State, next ratelimit.State, now time.Time, expiresAt time.Time) (bool, error) {
+	// A few other expired states are removed with each update, skipping any
+	// being updated concurrently, and an expired state for this key is
+	// replaced
+	err := l.s.queryRow(ctx, scanNothing, `
+WITH expired AS (
+    DELETE FROM goapi.rate_limits
+    WHERE key IN (
+        SELECT key FROM goapi.rate_limits
+        WHERE expires_at <= $6 AND key <> $1
+        LIMIT 10
+        FOR UPDATE SKIP LOCKED
+    )
+)
+INSERT INTO goapi.rate_limits (key, value, previous, time, version, expires_at)
+VALUES ($1, $2, $3, $4, $5 + 1, $7)
+ON CONFLICT (key) DO UPDATE
+SET value = EXCLUDED.value, previous = EXCLUDED.previous, time = EXCLUDED.time, version = EXCLUDED.version, expires_at = EXCLUDED.expires_at
+WHERE goapi.rate_limits.version = $5 OR goapi.rate_limits.expires_at <= $6
+RETURNING key`,
+		key,
+		next.Value,
+		next.Previous,
+		next.Time,
+		previous.Version,
+		now,
+		expiresAt,
+	)
+
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	default:
+		return false, err
+	}
+}
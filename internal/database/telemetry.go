@@ -0,0 +1,169 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+
+	telemetry "go-chi-api/internal/otel"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	apimetric "go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "go-chi-api/internal/database"
+
+var (
+	// Matches placeholders, which are kept, and string and numeric literals,
+	// which are replaced so that statements never carry values
+	literalPattern    = regexp.MustCompile(`\$\d+|'(?:[^']|'')*'|\b\d+(?:\.\d+)?\b`)
+	collectionPattern = regexp.MustCompile(`(?i)\b(?:from|into|update)\s+([\w.]+)`)
+)
+
+var tracer = otel.Tracer(instrumentationName)
+
+// Runs query, scanning the row it returns with scan, within a client span
+// describing the query. A query returning no rows is not an error for the span
+func (s *service) queryRow(ctx context.Context, scan func(*sql.Row) error, query string, args ...any) error {
+	statement := sanitizeStatement(query)
+	operation, collection := describeStatement(statement)
+
+	name := operation
+	if collection != "" {
+		name += " " + collection
+	}
+
+	ctx, span := tracer.Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(s.attrs...),
+		trace.WithAttributes(
+			semconv.DBOperationName(operation),
+			semconv.DBCollectionName(collection),
+			semconv.DBQueryText(statement),
+		),
+	)
+	defer span.End()
+
+	err := scan(s.db.QueryRowContext(ctx, query, args...))
+	switch {
+	case err == nil:
+		span.SetAttributes(semconv.DBResponseReturnedRows(1))
+	case errors.Is(err, sql.ErrNoRows):
+		span.SetAttributes(semconv.DBResponseReturnedRows(0))
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// Pings the database within a client span
+func (s *service) ping(ctx context.Context) error {
+	ctx, span := tracer.Start(
+		ctx,
+		"PING",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(s.attrs...),
+		trace.WithAttributes(semconv.DBOperationName("PING")),
+	)
+	defer span.End()
+
+	err := s.db.PingContext(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// Registers gauges and counters observing the connection pool's statistics
+func (s *service) registerPoolMetrics() error {
+	meter := otel.Meter(instrumentationName)
+
+	count, err := meter.Int64ObservableUpDownCounter(
+		telemetry.MetricDBClientConnectionCount,
+		apimetric.WithDescription("Number of open connections, by state"),
+		apimetric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	maxCount, err := meter.Int64ObservableUpDownCounter(
+		telemetry.MetricDBClientConnectionMax,
+		apimetric.WithDescription("Maximum number of open connections allowed, or 0 if unlimited"),
+		apimetric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	waits, err := meter.Int64ObservableCounter(
+		telemetry.MetricDBClientConnectionWaits,
+		apimetric.WithDescription("Number of times a connection was waited for"),
+		apimetric.WithUnit("{wait}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	waitDuration, err := meter.Float64ObservableCounter(
+		telemetry.MetricDBClientConnectionWaitDuration,
+		apimetric.WithDescription("Total time spent waiting for a connection"),
+		apimetric.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+
+	pool := apimetric.WithAttributes(s.poolAttrs...)
+	used := apimetric.WithAttributes(slices.Concat(s.poolAttrs, []attribute.KeyValue{semconv.DBClientConnectionStateUsed})...)
+	idle := apimetric.WithAttributes(slices.Concat(s.poolAttrs, []attribute.KeyValue{semconv.DBClientConnectionStateIdle})...)
+	_, err = meter.RegisterCallback(func(ctx context.Context, o apimetric.Observer) error {
+		stats := s.db.Stats()
+		o.ObserveInt64(count, int64(stats.InUse), used)
+		o.ObserveInt64(count, int64(stats.Idle), idle)
+		o.ObserveInt64(maxCount, int64(stats.MaxOpenConnections), pool)
+		o.ObserveInt64(waits, stats.WaitCount, pool)
+		o.ObserveFloat64(waitDuration, stats.WaitDuration.Seconds(), pool)
+		return nil
+	}, count, maxCount, waits, waitDuration)
+
+	return err
+}
+
+// Collapses whitespace in query and replaces any literal values in it with ?
+func sanitizeStatement(query string) string {
+	statement := literalPattern.ReplaceAllStringFunc(query, func(match string) string {
+		if strings.HasPrefix(match, "$") {
+			return match
+		}
+		return "?"
+	})
+
+	return strings.Join(strings.Fields(statement), " ")
+}
+
+// Returns the statement's operation, such as SELECT, and the table it operates
+// on, if it can be found
+func describeStatement(statement string) (operation string, collection string) {
+	operation, _, _ = strings.Cut(statement, " ")
+	operation = strings.ToUpper(operation)
+
+	if match := collectionPattern.FindStringSubmatch(statement); match != nil {
+		collection = match[1]
+	}
+
+	return operation, collection
+}
@@ -33,14 +33,24 @@ type User struct {
 	DeletedAtTimestamp *time.Time
 }
 
-func NewUser(username string, passwordHash string) *User {
+func NewUser(username string, passwordHash string, createdAt time.Time) *User {
 	return &User{
 		Id:                 0,
 		Username:           username,
 		Status:             Active,
 		PasswordHash:       passwordHash,
-		CreatedAtTimestamp: time.Now().UTC(),
+		CreatedAtTimestamp: createdAt.UTC(),
 		UpdatedAtTimestamp: nil,
 		DeletedAtTimestamp: nil,
 	}
 }
+
+// Returns when the user was last changed, which is when it was created if it
+// has never been updated
+func (u *User) LastModified() time.Time {
+	if u.UpdatedAtTimestamp != nil {
+		return *u.UpdatedAtTimestamp
+	}
+
+	return u.CreatedAtTimestamp
+}
@@ -0,0 +1,233 @@
+// Package health runs the checks subsystems register to report whether the
+// service and its dependencies are healthy
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	Healthy   Status = "Healthy"
+	Degraded  Status = "Degraded"
+	Unhealthy Status = "Unhealthy"
+)
+
+type (
+	Status string
+
+	// Checks the health of a subsystem. It should return promptly once ctx is
+	// done
+	Checker interface {
+		Check(ctx context.Context) Result
+	}
+
+	CheckerFunc func(ctx context.Context) Result
+
+	Result struct {
+		Status      Status
+		Description string
+
+		// Details of the subsystem's state, reported alongside its status
+		Data map[string]any
+	}
+
+	// A checker registered under a name. An unhealthy required check makes the
+	// service unhealthy, while an unhealthy optional check only degrades it
+	Check struct {
+		Name     string
+		Checker  Checker
+		Required bool
+
+		// How long the checker may run before it is considered unhealthy,
+		// and how long its result is reused for. Zero values use the
+		// registry's defaults
+		Timeout  time.Duration
+		CacheFor time.Duration
+	}
+
+	CheckResult struct {
+		Name     string
+		Required bool
+		Result
+		Duration time.Duration
+	}
+
+	Report struct {
+		Status   Status
+		Duration time.Duration
+		Checks   []CheckResult
+	}
+
+	// Holds the checks registered by each subsystem, and the service's
+	// lifecycle state which the startup and readiness probes report
+	Registry struct {
+		now             func() time.Time
+		defaultTimeout  time.Duration
+		defaultCacheFor time.Duration
+
+		mu     sync.RWMutex
+		checks []*registeredCheck
+
+		started  atomic.Bool
+		draining atomic.Bool
+	}
+
+	registeredCheck struct {
+		Check
+
+		// Held while the checker runs, so concurrent probes share one run
+		mu       sync.Mutex
+		cached   CheckResult
+		cachedAt time.Time
+	}
+)
+
+var ErrDuplicateCheck = errors.New("A health check with this name is already registered")
+
+func (f CheckerFunc) Check(ctx context.Context) Result {
+	return f(ctx)
+}
+
+// Creates a registry measuring time with now, whose checks default to timeout
+// and caching their results for cacheFor
+func NewRegistry(now func() time.Time, timeout time.Duration, cacheFor time.Duration) *Registry {
+	return &Registry{
+		now:             now,
+		defaultTimeout:  timeout,
+		defaultCacheFor: cacheFor,
+	}
+}
+
+func (r *Registry) Register(check Check) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, registered := range r.checks {
+		if registered.Name == check.Name {
+			return ErrDuplicateCheck
+		}
+	}
+
+	if check.Timeout == 0 {
+		check.Timeout = r.defaultTimeout
+	}
+	if check.CacheFor == 0 {
+		check.CacheFor = r.defaultCacheFor
+	}
+
+	r.checks = append(r.checks, &registeredCheck{Check: check})
+	return nil
+}
+
+// Runs every check concurrently, reusing results which are still cached
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := r.checks
+	r.mu.RUnlock()
+
+	start := r.now()
+	report := Report{Status: Healthy, Checks: make([]CheckResult, len(checks))}
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = r.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	for _, check := range report.Checks {
+		report.Status = worst(report.Status, impact(check))
+	}
+	report.Duration = r.now().Sub(start)
+
+	if report.Status != Unhealthy {
+		r.started.Store(true)
+	}
+
+	return report
+}
+
+// Reports whether every required check has passed at least once
+func (r *Registry) Started() bool {
+	return r.started.Load()
+}
+
+// Marks the service as shutting down, after which it is never ready
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+func (r *Registry) run(ctx context.Context, check *registeredCheck) CheckResult {
+	check.mu.Lock()
+	defer check.mu.Unlock()
+
+	if !check.cachedAt.IsZero() && r.now().Sub(check.cachedAt) < check.CacheFor {
+		return check.cached
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	start := r.now()
+	results := make(chan Result, 1)
+	go func() {
+		results <- check.Checker.Check(checkCtx)
+	}()
+
+	var result Result
+	select {
+	case result = <-results:
+	case <-checkCtx.Done():
+		// The caller gave up rather than the check, which says nothing about
+		// the subsystem's health, so the result is not cached
+		if err := ctx.Err(); err != nil {
+			return CheckResult{
+				Name:     check.Name,
+				Required: check.Required,
+				Result:   Result{Status: Unhealthy, Description: err.Error()},
+				Duration: r.now().Sub(start),
+			}
+		}
+
+		result = Result{Status: Unhealthy, Description: "Timed out after " + check.Timeout.String()}
+	}
+
+	check.cached = CheckResult{
+		Name:     check.Name,
+		Required: check.Required,
+		Result:   result,
+		Duration: r.now().Sub(start),
+	}
+	check.cachedAt = r.now()
+
+	return check.cached
+}
+
+// Returns the effect of a check's result on the service's status
+func impact(check CheckResult) Status {
+	if check.Status == Unhealthy && !check.Required {
+		return Degraded
+	}
+
+	return check.Status
+}
+
+func worst(a Status, b Status) Status {
+	rank := map[Status]int{Healthy: 0, Degraded: 1, Unhealthy: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+
+	return a
+}
@@ -0,0 +1,103 @@
+// Package idempotency stores the responses to requests made with an
+// Idempotency-Key, so that retries of the request can be answered with the
+// original response instead of being handled again
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type (
+	// A response to replay
+	Response struct {
+		Status int
+		Header http.Header
+		Body   []byte
+	}
+
+	// The request which first claimed a key, and its response once handled
+	Record struct {
+		// Identifies the content of the request, so reuse of the key for a
+		// different request can be detected
+		Fingerprint string
+
+		// Nil while the request is still being handled
+		Response *Response
+
+		ExpiresAt time.Time
+	}
+
+	Store interface {
+		// Claims key for a request with fingerprint until expiresAt. If the
+		// key is already claimed and has not expired by now, the existing
+		// record is returned instead, and the claim is not made
+		Claim(ctx context.Context, key string, fingerprint string, now time.Time, expiresAt time.Time) (*Record, error)
+
+		// Saves the response to the request which claimed key
+		Complete(ctx context.Context, key string, response Response) error
+
+		// Releases key without a response, so a retry is handled as a new
+		// request, as when the first request failed
+		Release(ctx context.Context, key string) error
+	}
+)
+
+// How often MemoryStore removes expired records
+const purgeInterval = time.Minute
+
+// Stores records in memory, so they are only replayed by the instance which
+// handled the original request
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]*Record
+	lastPurge time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*Record)}
+}
+
+func (s *MemoryStore) Claim(ctx context.Context, key string, fingerprint string, now time.Time, expiresAt time.Time) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastPurge) >= purgeInterval {
+		for k, record := range s.records {
+			if !record.ExpiresAt.After(now) {
+				delete(s.records, k)
+			}
+		}
+		s.lastPurge = now
+	}
+
+	if record, ok := s.records[key]; ok && record.ExpiresAt.After(now) {
+		existing := *record
+		return &existing, nil
+	}
+
+	s.records[key] = &Record{Fingerprint: fingerprint, ExpiresAt: expiresAt}
+	return nil, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, key string, response Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok {
+		response.Header = response.Header.Clone()
+		record.Response = &response
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
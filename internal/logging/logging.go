@@ -0,0 +1,243 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const redacted = "[REDACTED]"
+
+// Attributes whose key contains any of these are never logged in full
+var sensitiveKeys = []string{
+	"password",
+	"secret",
+	"token",
+	"cookie",
+	"authorization",
+	"api_key",
+	"apikey",
+}
+
+type (
+	// Wraps another handler, adding trace correlation and request scoped
+	// attributes from the context to each record, and redacting sensitive
+	// attributes
+	contextHandler struct {
+		inner slog.Handler
+		// Groups opened by WithGroup and the attributes added within them,
+		// applied by Handle so the context's attributes stay at the top level
+		scopes []scope
+	}
+
+	// A group and the attributes added to it before any nested group
+	scope struct {
+		group string
+		attrs []slog.Attr
+	}
+
+	attrsKey struct{}
+
+	// Sends each record to every handler, provided it is at least level
+	fanoutHandler struct {
+		level    slog.Leveler
+		handlers []slog.Handler
+	}
+
+	// Request scoped attributes, which may be added to after being attached to
+	// a context so that later middleware can enrich earlier log records
+	attrSet struct {
+		mu    sync.Mutex
+		attrs []slog.Attr
+	}
+)
+
+// Creates a logger writing records to w as JSON, or as logfmt style text when
+// format is "text", and to any additional handlers, discarding records below
+// level
+func New(w io.Writer, format string, level slog.Leveler, handlers ...slog.Handler) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if format == "text" {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	if len(handlers) > 0 {
+		handler = &fanoutHandler{
+			level:    level,
+			handlers: append([]slog.Handler{handler}, handlers...),
+		}
+	}
+
+	return slog.New(NewHandler(handler))
+}
+
+// Wraps inner so that records logged with a context include its trace and
+// span ids and any attributes added with AddAttrs, and sensitive attributes
+// are redacted before reaching inner
+func NewHandler(inner slog.Handler) slog.Handler {
+	return &contextHandler{inner: inner}
+}
+
+// Parses a level name as accepted by slog.Level, defaulting to info
+func ParseLevel(name string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return slog.LevelInfo
+	}
+
+	return level
+}
+
+// Returns a copy of ctx carrying a new set of request scoped attributes,
+// initially holding attrs
+func NewContext(ctx context.Context, attrs ...slog.Attr) context.Context {
+	return context.WithValue(ctx, attrsKey{}, &attrSet{attrs: attrs})
+}
+
+// Adds attrs to the request scoped attributes carried by ctx. Every record
+// logged afterwards with a context derived from the one given to NewContext
+// includes them. It does nothing if ctx carries no attributes
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	set, ok := ctx.Value(attrsKey{}).(*attrSet)
+	if !ok {
+		return
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	set.attrs = append(set.attrs, attrs...)
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, redact(attr))
+		return true
+	})
+
+	for i := len(h.scopes) - 1; i >= 0; i-- {
+		attrs = append(append([]slog.Attr{}, h.scopes[i].attrs...), attrs...)
+		attrs = []slog.Attr{{Key: h.scopes[i].group, Value: slog.GroupValue(attrs...)}}
+	}
+
+	record := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	record.AddAttrs(attrs...)
+
+	if set, ok := ctx.Value(attrsKey{}).(*attrSet); ok {
+		set.mu.Lock()
+		for _, attr := range set.attrs {
+			record.AddAttrs(redact(attr))
+		}
+		set.mu.Unlock()
+	}
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redactedAttrs[i] = redact(attr)
+	}
+
+	if len(h.scopes) == 0 {
+		return &contextHandler{inner: h.inner.WithAttrs(redactedAttrs)}
+	}
+
+	scopes := append([]scope{}, h.scopes...)
+	last := &scopes[len(scopes)-1]
+	last.attrs = append(append([]slog.Attr{}, last.attrs...), redactedAttrs...)
+	return &contextHandler{inner: h.inner, scopes: scopes}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	scopes := append(append([]scope{}, h.scopes...), scope{group: name})
+	return &contextHandler{inner: h.inner, scopes: scopes}
+}
+
+func (h *fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level < h.level.Level() {
+		return false
+	}
+
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, r.Level) {
+			err = errors.Join(err, handler.Handle(ctx, r.Clone()))
+		}
+	}
+
+	return err
+}
+
+func (h *fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+
+	return &fanoutHandler{level: h.level, handlers: handlers}
+}
+
+func (h *fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithGroup(name)
+	}
+
+	return &fanoutHandler{level: h.level, handlers: handlers}
+}
+
+func redact(attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		group := attr.Value.Group()
+		redactedGroup := make([]any, len(group))
+		for i, groupAttr := range group {
+			redactedGroup[i] = redact(groupAttr)
+		}
+		return slog.Group(attr.Key, redactedGroup...)
+	}
+
+	return attr
+}
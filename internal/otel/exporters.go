@@ -0,0 +1,326 @@
+package otel
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"go-chi-api/internal/config"
+	"go-chi-api/internal/requestid"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	exporterOtlp       = "otlp"
+	exporterPrometheus = "prometheus"
+	exporterConsole    = "console"
+	exporterNone       = "none"
+
+	protocolGrpc = "grpc"
+)
+
+var ErrInvalidOtlpHeaders = errors.New("OTLP headers must be comma separated key=value pairs")
+
+// Settings common to every OTLP exporter, converted from config.Otlp
+type otlpOptions struct {
+	grpc        bool
+	endpoint    string
+	headers     map[string]string
+	compression string
+	insecure    bool
+	tlsConfig   *tls.Config
+}
+
+func newOtlpOptions(cfg config.Otlp) (*otlpOptions, error) {
+	opts := &otlpOptions{
+		grpc:        cfg.Protocol == protocolGrpc,
+		endpoint:    strings.TrimSuffix(cfg.Endpoint, "/"),
+		compression: cfg.Compression,
+		insecure:    cfg.Insecure,
+	}
+
+	if cfg.Headers != "" {
+		opts.headers = make(map[string]string)
+		for _, pair := range strings.Split(cfg.Headers, ",") {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, ErrInvalidOtlpHeaders
+			}
+
+			value, err := url.PathUnescape(strings.TrimSpace(value))
+			if err != nil {
+				return nil, ErrInvalidOtlpHeaders
+			}
+
+			opts.headers[strings.TrimSpace(key)] = value
+		}
+	}
+
+	if cfg.Certificate != "" || cfg.ClientCertificate != "" {
+		opts.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if cfg.Certificate != "" {
+		pem, err := os.ReadFile(cfg.Certificate)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.tlsConfig.RootCAs = x509.NewCertPool()
+		if !opts.tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in %s", cfg.Certificate)
+		}
+	}
+
+	if cfg.ClientCertificate != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertificate, cfg.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return opts, nil
+}
+
+// Returns the URL for the signal's OTLP/HTTP endpoint, which is the base
+// endpoint with the signal's path appended
+func (o *otlpOptions) httpEndpoint(signal string) string {
+	return o.endpoint + "/v1/" + signal
+}
+
+// Returns the client OTLP/HTTP exports are sent with, which passes on the
+// request id and trace context of the export's context and trusts the
+// configured certificates
+func (o *otlpOptions) httpClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if o.tlsConfig != nil {
+		transport.TLSClientConfig = o.tlsConfig
+	}
+	return &http.Client{Transport: &requestid.Transport{Base: transport}}
+}
+
+func newSpanExporter(ctx context.Context, cfg *config.Telemetry) (trace.SpanExporter, error) {
+	switch cfg.TracesExporter {
+	case exporterNone:
+		return nil, nil
+	case exporterConsole:
+		return stdouttrace.New()
+	}
+
+	o, err := newOtlpOptions(cfg.Otlp)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.grpc {
+		var opts []otlptracegrpc.Option
+		if o.endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpointURL(o.endpoint))
+		}
+		if o.headers != nil {
+			opts = append(opts, otlptracegrpc.WithHeaders(o.headers))
+		}
+		if o.compression != "" {
+			opts = append(opts, otlptracegrpc.WithCompressor(o.compression))
+		}
+		if o.insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		} else if o.tlsConfig != nil {
+			opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(o.tlsConfig)))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	}
+
+	var opts []otlptracehttp.Option
+	if o.endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(o.httpEndpoint("traces")))
+	}
+	if o.headers != nil {
+		opts = append(opts, otlptracehttp.WithHeaders(o.headers))
+	}
+	if o.compression != "" {
+		compression := otlptracehttp.NoCompression
+		if o.compression == "gzip" {
+			compression = otlptracehttp.GzipCompression
+		}
+		opts = append(opts, otlptracehttp.WithCompression(compression))
+	}
+	if o.insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	opts = append(opts, otlptracehttp.WithHTTPClient(o.httpClient()))
+	return otlptracehttp.New(ctx, opts...)
+}
+
+func newMetricExporter(ctx context.Context, cfg *config.Telemetry) (metric.Exporter, error) {
+	switch cfg.MetricsExporter {
+	case exporterNone:
+		return nil, nil
+	case exporterConsole:
+		return stdoutmetric.New()
+	}
+
+	o, err := newOtlpOptions(cfg.Otlp)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.grpc {
+		var opts []otlpmetricgrpc.Option
+		if o.endpoint != "" {
+			opts = append(opts, otlpmetricgrpc.WithEndpointURL(o.endpoint))
+		}
+		if o.headers != nil {
+			opts = append(opts, otlpmetricgrpc.WithHeaders(o.headers))
+		}
+		if o.compression != "" {
+			opts = append(opts, otlpmetricgrpc.WithCompressor(o.compression))
+		}
+		if o.insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		} else if o.tlsConfig != nil {
+			opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(o.tlsConfig)))
+		}
+		return otlpmetricgrpc.New(ctx, opts...)
+	}
+
+	var opts []otlpmetrichttp.Option
+	if o.endpoint != "" {
+		opts = append(opts, otlpmetrichttp.WithEndpointURL(o.httpEndpoint("metrics")))
+	}
+	if o.headers != nil {
+		opts = append(opts, otlpmetrichttp.WithHeaders(o.headers))
+	}
+	if o.compression != "" {
+		compression := otlpmetrichttp.NoCompression
+		if o.compression == "gzip" {
+			compression = otlpmetrichttp.GzipCompression
+		}
+		opts = append(opts, otlpmetrichttp.WithCompression(compression))
+	}
+	if o.insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	}
+	opts = append(opts, otlpmetrichttp.WithHTTPClient(o.httpClient()))
+	return otlpmetrichttp.New(ctx, opts...)
+}
+
+// Returns a reader which the returned handler collects from whenever it is
+// scraped, rather than pushing metrics to an exporter. The handler also serves
+// process metrics, and exemplars linking samples to traces when the scraper
+// accepts OpenMetrics
+func newPrometheusReader() (metric.Reader, http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})); err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+	})
+
+	return reader, handler, nil
+}
+
+func newLogExporter(ctx context.Context, cfg *config.Telemetry) (sdklog.Exporter, error) {
+	switch cfg.LogsExporter {
+	case exporterNone:
+		return nil, nil
+	case exporterConsole:
+		return stdoutlog.New()
+	}
+
+	o, err := newOtlpOptions(cfg.Otlp)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.grpc {
+		var opts []otlploggrpc.Option
+		if o.endpoint != "" {
+			opts = append(opts, otlploggrpc.WithEndpointURL(o.endpoint))
+		}
+		if o.headers != nil {
+			opts = append(opts, otlploggrpc.WithHeaders(o.headers))
+		}
+		if o.compression != "" {
+			opts = append(opts, otlploggrpc.WithCompressor(o.compression))
+		}
+		if o.insecure {
+			opts = append(opts, otlploggrpc.WithInsecure())
+		} else if o.tlsConfig != nil {
+			opts = append(opts, otlploggrpc.WithTLSCredentials(credentials.NewTLS(o.tlsConfig)))
+		}
+		return otlploggrpc.New(ctx, opts...)
+	}
+
+	var opts []otlploghttp.Option
+	if o.endpoint != "" {
+		opts = append(opts, otlploghttp.WithEndpointURL(o.httpEndpoint("logs")))
+	}
+	if o.headers != nil {
+		opts = append(opts, otlploghttp.WithHeaders(o.headers))
+	}
+	if o.compression != "" {
+		compression := otlploghttp.NoCompression
+		if o.compression == "gzip" {
+			compression = otlploghttp.GzipCompression
+		}
+		opts = append(opts, otlploghttp.WithCompression(compression))
+	}
+	if o.insecure {
+		opts = append(opts, otlploghttp.WithInsecure())
+	}
+	opts = append(opts, otlploghttp.WithHTTPClient(o.httpClient()))
+	return otlploghttp.New(ctx, opts...)
+}
+
+// Builds the sampler named by OTEL_TRACES_SAMPLER. Ratio based samplers use
+// ratio, so that the ratio can change when the configuration is reloaded
+func newSampler(name string, ratio trace.Sampler) trace.Sampler {
+	switch name {
+	case "always_on":
+		return trace.AlwaysSample()
+	case "always_off":
+		return trace.NeverSample()
+	case "traceidratio":
+		return ratio
+	case "parentbased_always_on":
+		return trace.ParentBased(trace.AlwaysSample())
+	case "parentbased_always_off":
+		return trace.ParentBased(trace.NeverSample())
+	default:
+		return trace.ParentBased(ratio)
+	}
+}
@@ -0,0 +1,212 @@
+package otel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	apimetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type (
+	httpInstruments struct {
+		tracer         trace.Tracer
+		duration       apimetric.Float64Histogram
+		activeRequests apimetric.Int64UpDownCounter
+		requestSize    apimetric.Int64Histogram
+		responseSize   apimetric.Int64Histogram
+	}
+
+	// Counts the bytes read from a request body
+	countingBody struct {
+		io.ReadCloser
+		n atomic.Int64
+	}
+)
+
+// Bucket boundaries for http.server.request.duration recommended by the
+// semantic conventions, in seconds
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// Returns middleware which continues the trace propagated by the request
+// headers with a server span, and records HTTP server metrics. Spans and the
+// http.route attribute use the pattern of the matched chi route rather than the
+// request path, so it must be used within a chi router
+func NewHTTPMiddleware() (func(http.Handler) http.Handler, error) {
+	meter := otel.Meter(instrumentationName)
+	instruments := &httpInstruments{tracer: otel.Tracer(instrumentationName)}
+
+	var err error
+	var errs []error
+	instruments.duration, err = meter.Float64Histogram(
+		MetricHTTPServerRequestDuration,
+		apimetric.WithDescription("Duration of HTTP server requests"),
+		apimetric.WithUnit("s"),
+		apimetric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	errs = append(errs, err)
+
+	instruments.activeRequests, err = meter.Int64UpDownCounter(
+		MetricHTTPServerActiveRequests,
+		apimetric.WithDescription("Number of HTTP server requests in flight"),
+		apimetric.WithUnit("{request}"),
+	)
+	errs = append(errs, err)
+
+	instruments.requestSize, err = meter.Int64Histogram(
+		MetricHTTPServerRequestSize,
+		apimetric.WithDescription("Size of HTTP server request bodies"),
+		apimetric.WithUnit("By"),
+	)
+	errs = append(errs, err)
+
+	instruments.responseSize, err = meter.Int64Histogram(
+		MetricHTTPServerResponseSize,
+		apimetric.WithDescription("Size of HTTP server response bodies"),
+		apimetric.WithUnit("By"),
+	)
+	errs = append(errs, err)
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return instruments.middleware, nil
+}
+
+func (i *httpInstruments) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+
+		method := requestMethod(r.Method)
+		protocolVersion := fmt.Sprintf("%d.%d", r.ProtoMajor, r.ProtoMinor)
+		baseAttrs := []attribute.KeyValue{method, semconv.URLScheme(scheme)}
+
+		ctx, span := i.tracer.Start(
+			ctx,
+			r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				method,
+				semconv.URLScheme(scheme),
+				semconv.URLPath(r.URL.Path),
+				semconv.ServerAddress(r.Host),
+				semconv.UserAgentOriginal(r.UserAgent()),
+				semconv.NetworkProtocolVersion(protocolVersion),
+			),
+			trace.WithAttributes(clientAttributes(r.RemoteAddr)...),
+		)
+		defer span.End()
+
+		i.activeRequests.Add(ctx, 1, apimetric.WithAttributes(baseAttrs...))
+		defer i.activeRequests.Add(ctx, -1, apimetric.WithAttributes(baseAttrs...))
+
+		var body *countingBody
+		if r.Body != nil && r.Body != http.NoBody {
+			body = &countingBody{ReadCloser: r.Body}
+			r.Body = body
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		attrs := append(
+			baseAttrs,
+			semconv.HTTPResponseStatusCode(status),
+			semconv.NetworkProtocolVersion(protocolVersion),
+		)
+
+		if route := routePattern(r); route != "" {
+			attrs = append(attrs, semconv.HTTPRoute(route))
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			errorType := semconv.ErrorTypeKey.String(strconv.Itoa(status))
+			attrs = append(attrs, errorType)
+			span.SetAttributes(errorType)
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+
+		measurement := apimetric.WithAttributes(attrs...)
+		i.duration.Record(ctx, time.Since(start).Seconds(), measurement)
+		i.responseSize.Record(ctx, int64(ww.BytesWritten()), measurement)
+
+		requestSize := r.ContentLength
+		if body != nil {
+			requestSize = body.n.Load()
+		}
+		if requestSize >= 0 {
+			i.requestSize.Record(ctx, requestSize, measurement)
+		}
+	})
+}
+
+// Returns the http.request.method attribute, normalizing methods not known to
+// the semantic conventions to _OTHER to bound cardinality
+func requestMethod(method string) attribute.KeyValue {
+	switch method {
+	case http.MethodConnect, http.MethodDelete, http.MethodGet, http.MethodHead,
+		http.MethodOptions, http.MethodPatch, http.MethodPost, http.MethodPut,
+		http.MethodTrace:
+		return semconv.HTTPRequestMethodKey.String(method)
+	default:
+		return semconv.HTTPRequestMethodOther
+	}
+}
+
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+
+	return ""
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+// Returns the client.address and client.port attributes of a request's remote
+// address, which has no port once taken from the forwarding headers
+func clientAttributes(remoteAddr string) []attribute.KeyValue {
+	host, port, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return []attribute.KeyValue{semconv.ClientAddress(remoteAddr)}
+	}
+
+	attrs := []attribute.KeyValue{semconv.ClientAddress(host)}
+	if port, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, semconv.ClientPort(port))
+	}
+
+	return attrs
+}
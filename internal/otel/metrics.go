@@ -0,0 +1,33 @@
+package otel
+
+// Names of the metrics emitted by this service. HTTP and database metrics
+// follow the OpenTelemetry semantic conventions where they define one
+const (
+	MetricConfigReloads = "config.reloads"
+
+	MetricHTTPServerRequestDuration = "http.server.request.duration"
+	MetricHTTPServerActiveRequests  = "http.server.active_requests"
+	MetricHTTPServerRequestSize     = "http.server.request.body.size"
+	MetricHTTPServerResponseSize    = "http.server.response.body.size"
+
+	MetricDBClientConnectionCount        = "db.client.connection.count"
+	MetricDBClientConnectionMax          = "db.client.connection.max"
+	MetricDBClientConnectionWaits        = "db.client.connection.waits"
+	MetricDBClientConnectionWaitDuration = "db.client.connection.wait_duration"
+
+	MetricAuthRegistrations           = "auth.registrations"
+	MetricAuthLogins                  = "auth.logins"
+	MetricAuthTokenValidationFailures = "auth.token.validation_failures"
+	MetricAuthPasswordHashDuration    = "auth.password.hash.duration"
+)
+
+// Names of the Go runtime metrics emitted by the runtime instrumentation New
+// starts
+const (
+	MetricGoMemoryUsed        = "go.memory.used"
+	MetricGoMemoryAllocated   = "go.memory.allocated"
+	MetricGoMemoryAllocations = "go.memory.allocations"
+	MetricGoMemoryGCGoal      = "go.memory.gc.goal"
+	MetricGoGoroutineCount    = "go.goroutine.count"
+	MetricGoConfigGogc        = "go.config.gogc"
+)
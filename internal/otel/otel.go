@@ -3,26 +3,51 @@ package otel
 import (
 	"context"
 	"errors"
+	"go-chi-api/internal/config"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
 
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
-	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log/global"
+	apimetric "go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 )
 
+const instrumentationName = "go-chi-api/internal/otel"
+
 type Service interface {
 	Shutdown(context.Context) error
+
+	// Returns the handler serving metrics to be scraped, or nil if metrics
+	// are not exported to Prometheus
+	MetricsHandler() http.Handler
 }
 
 type service struct {
-	shutdownFuncs []func(context.Context) error
+	shutdownFuncs  []func(context.Context) error
+	metricsHandler http.Handler
+}
+
+// Samples the ratio of traces given by the runtime configuration, which may
+// change whenever the configuration is reloaded
+type ratioSampler struct {
+	current atomic.Pointer[samplerHolder]
 }
 
-func New(ctx context.Context, serviceName string, version string) (serv Service, err error) {
+type samplerHolder struct {
+	trace.Sampler
+}
+
+func New(ctx context.Context, store *config.Store, version string) (serv Service, err error) {
 	var shutdownFuncs []func(context.Context) error
 	s := &service{shutdownFuncs: shutdownFuncs}
 
@@ -30,13 +55,15 @@ func New(ctx context.Context, serviceName string, version string) (serv Service,
 		err = errors.Join(inErr, s.Shutdown(ctx))
 	}
 
-	resource, err := initResource(ctx, serviceName, version)
+	cfg := store.Load()
+	resource, err := initResource(ctx, cfg.Telemetry.ServiceName, version)
 	if err != nil {
 		handleErr(err)
 		return
 	}
 
-	tp, err := initTracerProvider(resource)
+	sampler := newRatioSampler(cfg.Runtime.TraceSampleRatio)
+	tp, err := initTracerProvider(ctx, &cfg.Telemetry, resource, sampler)
 	if err != nil {
 		handleErr(err)
 		return
@@ -44,16 +71,51 @@ func New(ctx context.Context, serviceName string, version string) (serv Service,
 
 	s.shutdownFuncs = append(s.shutdownFuncs, tp.Shutdown)
 
-	mp, err := initMeterProvider(resource)
+	mp, metricsHandler, err := initMeterProvider(ctx, &cfg.Telemetry, resource)
 	if err != nil {
 		handleErr(err)
 		return
 	}
 
 	s.shutdownFuncs = append(s.shutdownFuncs, mp.Shutdown)
+	s.metricsHandler = metricsHandler
+
+	if err = runtime.Start(runtime.WithMeterProvider(mp)); err != nil {
+		handleErr(err)
+		return
+	}
+
+	lp, err := initLoggerProvider(ctx, &cfg.Telemetry, resource)
+	if err != nil {
+		handleErr(err)
+		return
+	}
+
+	s.shutdownFuncs = append(s.shutdownFuncs, lp.Shutdown)
+
+	reloads, err := mp.Meter(instrumentationName).Int64Counter(
+		MetricConfigReloads,
+		apimetric.WithDescription("Configuration reload attempts, by result"),
+		apimetric.WithUnit("{reload}"),
+	)
+	if err != nil {
+		handleErr(err)
+		return
+	}
+
+	store.OnReload(func(cfg *config.Config, err error) {
+		sampler.setRatio(cfg.Runtime.TraceSampleRatio)
+
+		result := "success"
+		if err != nil {
+			result = "failure"
+		}
+		reloads.Add(context.Background(), 1, apimetric.WithAttributes(attribute.String("result", result)))
+	})
 
 	otel.SetTracerProvider(tp)
 	otel.SetMeterProvider(mp)
+	global.SetLoggerProvider(lp)
 	otel.SetTextMapPropagator(
 		propagation.NewCompositeTextMapPropagator(
 			propagation.TraceContext{},
@@ -87,33 +149,94 @@ func initResource(ctx context.Context, serviceName string, version string) (res
 	)
 }
 
-func initTracerProvider(res *resource.Resource) (*trace.TracerProvider, error) {
-	exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+func initTracerProvider(ctx context.Context, cfg *config.Telemetry, res *resource.Resource, ratio trace.Sampler) (*trace.TracerProvider, error) {
+	opts := []trace.TracerProviderOption{
+		trace.WithSampler(newSampler(cfg.TracesSampler, ratio)),
+		trace.WithResource(res),
+	}
+
+	exporter, err := newSpanExporter(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
+	if exporter != nil {
+		opts = append(opts, trace.WithBatcher(exporter))
+	}
 
-	tp := trace.NewTracerProvider(
-		trace.WithSampler(trace.AlwaysSample()),
-		trace.WithBatcher(exporter),
-		trace.WithResource(res),
-	)
+	return trace.NewTracerProvider(opts...), nil
+}
+
+// Creates the meter provider, and when metrics are exported to Prometheus the
+// handler which serves them
+func initMeterProvider(ctx context.Context, cfg *config.Telemetry, res *resource.Resource) (*metric.MeterProvider, http.Handler, error) {
+	opts := []metric.Option{
+		metric.WithResource(res),
+	}
+
+	if cfg.MetricsExporter == exporterPrometheus {
+		reader, handler, err := newPrometheusReader()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		opts = append(opts, metric.WithReader(reader))
+		return metric.NewMeterProvider(opts...), handler, nil
+	}
 
-	return tp, nil
+	exporter, err := newMetricExporter(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if exporter != nil {
+		opts = append(opts, metric.WithReader(metric.NewPeriodicReader(exporter)))
+	}
+
+	return metric.NewMeterProvider(opts...), nil, nil
 }
 
-func initMeterProvider(res *resource.Resource) (*metric.MeterProvider, error) {
-	exporter, err := stdoutmetric.New()
+func initLoggerProvider(ctx context.Context, cfg *config.Telemetry, res *resource.Resource) (*sdklog.LoggerProvider, error) {
+	opts := []sdklog.LoggerProviderOption{
+		sdklog.WithResource(res),
+	}
+
+	exporter, err := newLogExporter(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
+	if exporter != nil {
+		opts = append(opts, sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)))
+	}
 
-	mp := metric.NewMeterProvider(
-		metric.WithResource(res),
-		metric.WithReader(metric.NewPeriodicReader(exporter)),
-	)
+	return sdklog.NewLoggerProvider(opts...), nil
+}
+
+// Returns a slog handler which emits records as OpenTelemetry log records,
+// correlated with the span in the record's context. Records are sent to the
+// global LoggerProvider, which New sets; any logged before then are dropped
+func NewLogHandler() slog.Handler {
+	return otelslog.NewHandler(instrumentationName)
+}
+
+func newRatioSampler(ratio float64) *ratioSampler {
+	s := &ratioSampler{}
+	s.setRatio(ratio)
+	return s
+}
+
+func (s *ratioSampler) setRatio(ratio float64) {
+	s.current.Store(&samplerHolder{trace.TraceIDRatioBased(ratio)})
+}
+
+func (s *ratioSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
+	return s.current.Load().ShouldSample(p)
+}
+
+func (s *ratioSampler) Description() string {
+	return s.current.Load().Description()
+}
 
-	return mp, nil
+func (s *service) MetricsHandler() http.Handler {
+	return s.metricsHandler
 }
 
 func (s *service) Shutdown(ctx context.Context) (err error) {
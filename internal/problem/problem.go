@@ -0,0 +1,141 @@
+// Package problem writes error responses as RFC 9457 problem details. Every
+// kind of problem the API returns is registered as a Type, so clients can rely
+// on its URI and status
+package problem
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go-chi-api/internal/requestid"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const ContentType = "application/problem+json"
+
+// Documents the problem types specific to this API
+const docsURI = "https://github.com/ryanseipp/go-chi-api/blob/main/docs/problems.md"
+
+type (
+	// A kind of problem, identified by its URI. The title is the same for
+	// every occurrence, while the detail of each Problem explains that
+	// occurrence
+	Type struct {
+		URI    string
+		Title  string
+		Status int
+	}
+
+	Problem struct {
+		Type   string `json:"type" example:"https://github.com/ryanseipp/go-chi-api/blob/main/docs/problems.md#validation-failed"`
+		Title  string `json:"title" example:"Validation Failed"`
+		Status int    `json:"status" example:"400"`
+		Detail string `json:"detail,omitempty" example:"The request body failed validation"`
+
+		// The path of the request the problem occurred in
+		Instance string `json:"instance,omitempty" example:"/v1.0/auth/register"`
+
+		// Identify the request in logs and traces
+		RequestId string `json:"request_id,omitempty" example:"4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"`
+		TraceId   string `json:"trace_id,omitempty" example:"4bf92f3577b34da6a3ce929d0e0e4736"`
+
+		Errors []FieldError `json:"errors,omitempty"`
+	}
+
+	// A problem with one field of the request
+	FieldError struct {
+		// JSON pointer to the field within the request body
+		Pointer string `json:"pointer" example:"/password"`
+		Detail  string `json:"detail" example:"password must be at least 16 characters"`
+	}
+)
+
+var registry = make(map[string]Type)
+
+var (
+	BadRequest           = Register(Type{"https://www.rfc-editor.org/rfc/rfc9110#section-15.5.1", "Bad Request", http.StatusBadRequest})
+	Unauthorized         = Register(Type{"https://www.rfc-editor.org/rfc/rfc9110#section-15.5.2", "Unauthorized", http.StatusUnauthorized})
+	NotFound             = Register(Type{"https://www.rfc-editor.org/rfc/rfc9110#section-15.5.5", "Not Found", http.StatusNotFound})
+	MethodNotAllowed     = Register(Type{"https://www.rfc-editor.org/rfc/rfc9110#section-15.5.6", "Method Not Allowed", http.StatusMethodNotAllowed})
+	NotAcceptable        = Register(Type{"https://www.rfc-editor.org/rfc/rfc9110#section-15.5.7", "Not Acceptable", http.StatusNotAcceptable})
+	PreconditionFailed   = Register(Type{"https://www.rfc-editor.org/rfc/rfc9110#section-15.5.13", "Precondition Failed", http.StatusPreconditionFailed})
+	ContentTooLarge      = Register(Type{"https://www.rfc-editor.org/rfc/rfc9110#section-15.5.14", "Content Too Large", http.StatusRequestEntityTooLarge})
+	UnsupportedMediaType = Register(Type{"https://www.rfc-editor.org/rfc/rfc9110#section-15.5.16", "Unsupported Media Type", http.StatusUnsupportedMediaType})
+	TooManyRequests      = Register(Type{"https://www.rfc-editor.org/rfc/rfc6585#section-4", "Too Many Requests", http.StatusTooManyRequests})
+	InternalServerError  = Register(Type{"https://www.rfc-editor.org/rfc/rfc9110#section-15.6.1", "Internal Server Error", http.StatusInternalServerError})
+	GatewayTimeout       = Register(Type{"https://www.rfc-editor.org/rfc/rfc9110#section-15.6.5", "Gateway Timeout", http.StatusGatewayTimeout})
+
+	MalformedBody        = Register(Type{docsURI + "#malformed-body", "Malformed Body", http.StatusBadRequest})
+	ValidationFailed     = Register(Type{docsURI + "#validation-failed", "Validation Failed", http.StatusBadRequest})
+	InvalidCredentials   = Register(Type{docsURI + "#invalid-credentials", "Invalid Credentials", http.StatusUnauthorized})
+	InvalidToken         = Register(Type{docsURI + "#invalid-token", "Invalid Token", http.StatusUnauthorized})
+	IdempotencyKeyInUse  = Register(Type{docsURI + "#idempotency-key-in-use", "Idempotency Key In Use", http.StatusConflict})
+	IdempotencyKeyReused = Register(Type{docsURI + "#idempotency-key-reused", "Idempotency Key Reused", http.StatusUnprocessableEntity})
+	CsrfTokenInvalid     = Register(Type{docsURI + "#csrf-token-invalid", "CSRF Token Invalid", http.StatusForbidden})
+	OriginNotAllowed     = Register(Type{docsURI + "#origin-not-allowed", "Origin Not Allowed", http.StatusForbidden})
+)
+
+// Registers a problem type, panicking if its URI is already registered
+func Register(t Type) Type {
+	if _, ok := registry[t.URI]; ok {
+		panic(fmt.Sprintf("problem type %s is already registered", t.URI))
+	}
+
+	registry[t.URI] = t
+	return t
+}
+
+// Returns every registered problem type
+func Types() []Type {
+	types := make([]Type, 0, len(registry))
+	for _, t := range registry {
+		types = append(types, t)
+	}
+	slices.SortFunc(types, func(a, b Type) int { return strings.Compare(a.URI, b.URI) })
+
+	return types
+}
+
+// Creates a problem of this type, with detail explaining this occurrence
+func (t Type) New(detail string, errors ...FieldError) *Problem {
+	return &Problem{
+		Type:   t.URI,
+		Title:  t.Title,
+		Status: t.Status,
+		Detail: detail,
+		Errors: errors,
+	}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+
+	return p.Title + ": " + p.Detail
+}
+
+// Writes p as the response to r, identifying the request it occurred in
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.Instance = r.URL.Path
+	p.RequestId = requestid.FromContext(r.Context())
+	if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.HasTraceID() {
+		p.TraceId = spanContext.TraceID().String()
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+var (
+	// Refills a bucket of Requests tokens evenly over Period, taking one for
+	// each request. Allows bursts of up to Requests after a quiet period
+	TokenBucket Algorithm = tokenBucket{}
+
+	// Counts requests in fixed windows of Period, estimating the count in the
+	// Period before each request by weighting the previous window by how much
+	// of it overlaps. Unlike fixed windows, it does not allow twice the limit
+	// across the boundary between two windows
+	SlidingWindow Algorithm = slidingWindow{}
+)
+
+// State.Value is the tokens in the bucket as of State.Time
+type tokenBucket struct{}
+
+func (tokenBucket) Take(state State, limit Limit, now time.Time) (State, Result, time.Time) {
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Period.Seconds()
+
+	tokens := capacity
+	if !state.Time.IsZero() {
+		tokens = math.Min(capacity, state.Value+now.Sub(state.Time).Seconds()*rate)
+	}
+
+	result := Result{Limit: limit.Requests}
+	if tokens >= 1 {
+		result.Allowed = true
+		tokens--
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / rate)
+	}
+
+	result.Remaining = int(tokens)
+	result.Reset = seconds((capacity - tokens) / rate)
+
+	next := State{Value: tokens, Time: now, Version: state.Version}
+	return next, result, now.Add(result.Reset)
+}
+
+// State.Value and State.Previous are the requests counted in the window
+// starting at State.Time and the one before it
+type slidingWindow struct{}
+
+func (slidingWindow) Take(state State, limit Limit, now time.Time) (State, Result, time.Time) {
+	start := now.Truncate(limit.Period)
+	next := State{Time: start, Version: state.Version}
+	switch {
+	case state.Time.Equal(start):
+		next.Value, next.Previous = state.Value, state.Previous
+	case state.Time.Equal(start.Add(-limit.Period)):
+		next.Previous = state.Value
+	}
+
+	period := limit.Period.Seconds()
+	requests := float64(limit.Requests)
+	elapsed := now.Sub(start).Seconds()
+	estimate := next.Previous*(1-elapsed/period) + next.Value
+
+	result := Result{Limit: limit.Requests, Reset: start.Add(limit.Period).Sub(now)}
+	if estimate+1 <= requests {
+		result.Allowed = true
+		next.Value++
+		estimate++
+	} else {
+		result.RetryAfter = retryAfter(next, requests, period, elapsed)
+	}
+
+	result.Remaining = int(math.Max(0, requests-estimate))
+
+	// The window is still needed while it is the previous one
+	return next, result, start.Add(2 * limit.Period)
+}
+
+// Returns how long until the estimate falls enough for another request, as
+// the previous window's weight falls and the current window becomes the
+// previous one
+func retryAfter(state State, requests float64, period float64, elapsed float64) time.Duration {
+	if state.Value <= requests-1 && state.Previous > 0 {
+		// Solves Previous*(1-t/period) + Value = requests-1 for t
+		t := period * (1 - (requests-1-state.Value)/state.Previous)
+		return seconds(t - elapsed)
+	}
+
+	// The same, once the current window becomes the previous one
+	t := period * (1 - (requests-1)/state.Value)
+	return seconds(period - elapsed + t)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
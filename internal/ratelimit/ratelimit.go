@@ -0,0 +1,159 @@
+// Package ratelimit decides whether requests are within a limit on how many
+// may be made in a period. Algorithms decide how requests are counted, and
+// stores keep each key's count, so that any algorithm can be used with any
+// store
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrContention = errors.New("Rate limit state changed by too many concurrent requests")
+
+type (
+	// Allows Requests in every Period
+	Limit struct {
+		Requests int
+		Period   time.Duration
+	}
+
+	// The decision for one request
+	Result struct {
+		Allowed bool
+		Limit   int
+
+		// Requests that may still be made before being limited
+		Remaining int
+
+		// How long until the limit is fully restored
+		Reset time.Duration
+
+		// How long until a limited request may be retried, when not allowed
+		RetryAfter time.Duration
+	}
+
+	// The count kept for a key, whose meaning is up to the algorithm. The zero
+	// State is that of a key which has made no requests
+	State struct {
+		Value    float64
+		Previous float64
+		Time     time.Time
+
+		// Set by the store each time the state is saved, so concurrent
+		// changes can be detected
+		Version int64
+	}
+
+	Algorithm interface {
+		// Takes one request from state at now, returning the state after it,
+		// the decision, and when the state can be forgotten
+		Take(state State, limit Limit, now time.Time) (next State, result Result, expiresAt time.Time)
+	}
+
+	Store interface {
+		// Returns the state of key, or the zero State if it has none or its
+		// state expired before now
+		Get(ctx context.Context, key string, now time.Time) (State, error)
+
+		// Replaces the state of key with next, until expiresAt, if it is still
+		// previous. Reports whether it was replaced
+		CompareAndSwap(ctx context.Context, key string, previous State, next State, now time.Time, expiresAt time.Time) (bool, error)
+	}
+)
+
+// How often a request is retried when its state is changed concurrently
+const maxAttempts = 5
+
+// Applies a limit to requests by key
+type Limiter struct {
+	algorithm Algorithm
+	store     Store
+	limit     Limit
+}
+
+func New(algorithm Algorithm, store Store, limit Limit) *Limiter {
+	return &Limiter{algorithm: algorithm, store: store, limit: limit}
+}
+
+// Takes one request for key at now, returning whether it is allowed
+func (l *Limiter) Take(ctx context.Context, key string, now time.Time) (Result, error) {
+	for range maxAttempts {
+		state, err := l.store.Get(ctx, key, now)
+		if err != nil {
+			return Result{}, err
+		}
+
+		next, result, expiresAt := l.algorithm.Take(state, l.limit, now)
+		swapped, err := l.store.CompareAndSwap(ctx, key, state, next, now, expiresAt)
+		if err != nil {
+			return Result{}, err
+		}
+
+		if swapped {
+			return result, nil
+		}
+	}
+
+	return Result{}, ErrContention
+}
+
+// How often MemoryStore removes expired states
+const purgeInterval = time.Minute
+
+type memoryEntry struct {
+	state     State
+	expiresAt time.Time
+}
+
+// Stores states in memory, so each instance limits only the requests it
+// handles
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastPurge time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string, now time.Time) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && entry.expiresAt.After(now) {
+		return entry.state, nil
+	}
+
+	return State{}, nil
+}
+
+func (s *MemoryStore) CompareAndSwap(ctx context.Context, key string, previous State, next State, now time.Time, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastPurge) >= purgeInterval {
+		for k, entry := range s.entries {
+			if !entry.expiresAt.After(now) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastPurge = now
+	}
+
+	current := State{}
+	if entry, ok := s.entries[key]; ok && entry.expiresAt.After(now) {
+		current = entry.state
+	}
+
+	if current.Version != previous.Version {
+		return false, nil
+	}
+
+	next.Version = previous.Version + 1
+	s.entries[key] = memoryEntry{state: next, expiresAt: expiresAt}
+	return true, nil
+}
@@ -0,0 +1,89 @@
+// Package requestid identifies each request with an id the client can see,
+// which is attached to logs, problem details and spans, and passed on to the
+// services the request calls
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The header a request id is accepted from, returned in, and propagated with
+const Header = "X-Request-Id"
+
+// The longest request id accepted from a client
+const maxLength = 128
+
+type contextKey struct{}
+
+// Returns a copy of ctx carrying the request id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// Returns the request id carried by ctx, or "" if there is none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Reports whether id is acceptable from a client: at most 128 characters, of
+// letters, digits and the punctuation commonly used by other services' ids.
+// Anything else could be used to forge log lines or headers
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+
+	for _, c := range []byte(id) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '/', c == '+', c == '=', c == '@':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns a new request id. When ctx carries a span, the id is made of its
+// trace and span ids, so it can be looked up in the tracing backend
+func New(ctx context.Context) string {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		return spanContext.TraceID().String() + "-" + spanContext.SpanID().String()
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Propagates the request id and trace context of each request's context to
+// the service it is sent to
+type Transport struct {
+	// Sends the requests, or http.DefaultTransport if nil
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// A RoundTripper must not modify the request it is given
+	r = r.Clone(r.Context())
+	if id := FromContext(r.Context()); id != "" {
+		r.Header.Set(Header, id)
+	}
+	otel.GetTextMapPropagator().Inject(r.Context(), propagation.HeaderCarrier(r.Header))
+
+	return base.RoundTrip(r)
+}
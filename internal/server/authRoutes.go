@@ -1,9 +1,13 @@
 package server
 
 import (
+	"database/sql"
+	"errors"
 	"go-chi-api/internal/authentication"
+	"go-chi-api/internal/conditional"
+	"go-chi-api/internal/config"
 	"go-chi-api/internal/domain"
-	"log"
+	"go-chi-api/internal/problem"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -11,16 +15,24 @@ import (
 
 func (s *Server) authRouter(r chi.Router) {
 	r.Route("/auth", func(r chi.Router) {
-		r.Post("/register", s.registerUser)
-		r.Post("/login", s.loginUser)
+		r.Group(func(r chi.Router) {
+			r.Use(s.useRateLimit("auth"))
+			r.With(s.useIdempotency).Post("/register", s.registerUser)
+			r.Post("/login", s.loginUser)
+			r.Get("/csrf", s.getCsrfToken)
+		})
 
 		r.Route("/current", func(r chi.Router) {
 			r.Use(s.auth.UseAuthentication)
+			r.Use(s.useRateLimit("api"))
 			r.Get("/", s.getCurrentUser)
 		})
 	})
 }
 
+// Large enough for the longest username and password allowed
+const authBodyLimit = 4 << 10
+
 type LoginUserRequest struct {
 	Username string `json:"username" example:"myusername123" validate:"required,min=1,max=256"`
 	Password string `json:"password" example:"superpassword" format:"password" validate:"required,min=16,max=64"`
@@ -30,16 +42,23 @@ type LoginUserRequest struct {
 // @Summary Login user
 // @Description Log in user via username and password
 // @Tags auth
-// @Accept json
+// @Accept json,application/cbor,application/msgpack
+// @Param X-CSRF-Token header string false "CSRF token from /auth/csrf, required when the authentication cookie is sent"
 // @Param request body server.LoginUserRequest true "Login Request Body"
+// @Produce json,application/cbor,application/msgpack
 // @Success 204
-// @Failure 401
-// @Failure 500
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 406 {object} problem.Problem
+// @Failure 413 {object} problem.Problem
+// @Failure 415 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /v1/auth/login [post]
 func (s *Server) loginUser(w http.ResponseWriter, r *http.Request) {
-	var request LoginUserRequest
-	if err := s.decodeJson(w, r, &request); err != nil {
-		log.Println(err)
+	request, err := decodeBody[LoginUserRequest](s, w, r, WithMaxBytes(authBodyLimit), DisallowUnknownFields())
+	if err != nil {
 		return
 	}
 
@@ -51,15 +70,29 @@ func (s *Server) loginUser(w http.ResponseWriter, r *http.Request) {
 
 	hashResult, hashErr := s.auth.VerifyHashedPassword(request.Password, passwordHash)
 	if dbErr != nil || user == nil || hashErr != nil || hashResult == authentication.Invalid {
-		w.WriteHeader(http.StatusUnauthorized)
+		reason := reasonBadPassword
+		switch {
+		case errors.Is(dbErr, sql.ErrNoRows) || (dbErr == nil && user == nil):
+			reason = reasonUnknownUser
+		case dbErr != nil || hashErr != nil:
+			reason = reasonError
+		}
+
+		if reason == reasonError {
+			s.logger.ErrorContext(r.Context(), "Failed to verify credentials", "error", errors.Join(dbErr, hashErr))
+		}
+		s.metrics.recordLogin(r.Context(), resultFailure, reason)
+		problem.Write(w, r, problem.InvalidCredentials.New("The username or password is incorrect"))
 		return
 	}
 
+	var reason string
 	if hashResult == authentication.ValidRehashNeeded {
+		reason = reasonRehashNeeded
 		passwordHash, err := s.auth.HashPassword(request.Password)
 		if err != nil {
-			log.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			s.metrics.recordLogin(r.Context(), resultFailure, reasonError)
+			s.internalErrorResponse(w, r, "Failed to rehash password", err)
 			return
 		}
 
@@ -67,16 +100,17 @@ func (s *Server) loginUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := s.auth.SetAuthenticationCookie(w, user); err != nil {
-		log.Print(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.metrics.recordLogin(r.Context(), resultFailure, reasonError)
+		s.internalErrorResponse(w, r, "Failed to set authentication cookie", err)
 		return
 	}
 
+	s.metrics.recordLogin(r.Context(), resultSuccess, reason)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 type RegisterUserRequest struct {
-	Username string `json:"username" example:"myusername123" validate:"required,min=1,max=256"`
+	Username string `json:"username" example:"myusername123" validate:"required,min=1,max=256,username"`
 	Password string `json:"password" example:"superpassword" format:"password" validate:"required,min=16,max=64"`
 }
 
@@ -84,31 +118,45 @@ type RegisterUserRequest struct {
 // @Summary Register user
 // @Description Register user with the given username and password
 // @Tags auth
-// @Accept json
+// @Accept json,application/cbor,application/msgpack
+// @Param Idempotency-Key header string false "Replay the first response to retries with the same key"
+// @Param X-CSRF-Token header string false "CSRF token from /auth/csrf, required when the authentication cookie is sent"
 // @Param request body server.RegisterUserRequest true "Register Request Body"
-// @Success 204
-// @Failure 401
+// @Produce json,application/cbor,application/msgpack
+// @Success 201
+// @Header 201 {string} Idempotent-Replayed "Set to true when the response is replayed for a retry"
+// @Failure 400 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 406 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Failure 413 {object} problem.Problem
+// @Failure 415 {object} problem.Problem
+// @Failure 422 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /v1/auth/register [post]
 func (s *Server) registerUser(w http.ResponseWriter, r *http.Request) {
-	var request RegisterUserRequest
-	if err := s.decodeJson(w, r, &request); err != nil {
-		log.Println(err)
+	request, err := decodeBody[RegisterUserRequest](s, w, r, WithMaxBytes(authBodyLimit), DisallowUnknownFields())
+	if err != nil {
 		return
 	}
 
 	passwordHash, err := s.auth.HashPassword(request.Password)
 	if err != nil {
-		log.Println(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		s.metrics.recordRegistration(r.Context(), resultFailure, reasonError)
+		s.internalErrorResponse(w, r, "Failed to hash password", err)
 		return
 	}
 
-	user := domain.NewUser(request.Username, passwordHash)
+	user := domain.NewUser(request.Username, passwordHash, s.now())
 	if err := s.db.CreateUser(r.Context(), user); err != nil {
-		log.Println(err)
-		s.badRequestResponse(w, "Cannot create user with the information provided")
+		s.logger.InfoContext(r.Context(), "Failed to create user", "error", err)
+		s.metrics.recordRegistration(r.Context(), resultFailure, reasonRejected)
+		problem.Write(w, r, problem.BadRequest.New("Cannot create user with the information provided"))
+		return
 	}
 
+	s.metrics.recordRegistration(r.Context(), resultSuccess, "")
 	w.Header().Set("Location", "/auth/current")
 	w.WriteHeader(http.StatusCreated)
 }
@@ -124,16 +172,23 @@ type GetCurrentUserResponse struct {
 // @Summary Get current user details
 // @Description Gets the details of the authenticated user
 // @Tags auth
-// @Produce json
+// @Produce json,application/cbor,application/msgpack
 // @Success 200 {object} server.GetCurrentUserResponse
-// @Failure 401
+// @Header 200 {string} ETag "Entity tag of the representation, for If-None-Match"
+// @Header 200 {string} Last-Modified "When the user was last changed, for If-Modified-Since"
+// @Success 304
+// @Failure 401 {object} problem.Problem
+// @Failure 406 {object} problem.Problem
+// @Failure 412 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
 // @Router /v1/auth/current [get]
 func (s *Server) getCurrentUser(w http.ResponseWriter, r *http.Request) {
 	userId := r.Context().Value(authentication.ContextValueUserId).(int64)
 	user, err := s.db.GetUserById(r.Context(), userId)
 
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		problem.Write(w, r, problem.Unauthorized.New("The authenticated user no longer exists"))
 		return
 	}
 
@@ -144,5 +199,47 @@ func (s *Server) getCurrentUser(w http.ResponseWriter, r *http.Request) {
 		UpdatedAt: JsonTime{user.UpdatedAtTimestamp},
 	}
 
-	s.jsonResponse(w, &response)
+	// Private, as it describes the authenticated user, and revalidated on every
+	// use, as the user may change at any time
+	w.Header().Set("Cache-Control", "private, no-cache")
+	conditional.SetLastModified(w.Header(), user.LastModified())
+	s.respond(w, r, &response)
+}
+
+type CsrfTokenResponse struct {
+	Token string `json:"token" example:"8J2v0e8Qk3bX0yqZx7GgAq1cVbYQH1tq8wqU8tq3hJE.mF6ry8mE0vGJm4HqU5mV0q1cZ5l9mJpP3n8b6Yx1Hk4"`
+}
+
+// GetCsrfToken
+// @Summary Get CSRF token
+// @Description Issues a CSRF token for the current session, also setting it as a cookie. Requests that change state while logged in must send it in the X-CSRF-Token header. Fetch a new token after logging in
+// @Tags auth
+// @Produce json,application/cbor,application/msgpack
+// @Success 200 {object} server.CsrfTokenResponse
+// @Failure 406 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Failure 500 {object} problem.Problem
+// @Router /v1/auth/csrf [get]
+func (s *Server) getCsrfToken(w http.ResponseWriter, r *http.Request) {
+	session := ""
+	if cookie, err := r.Cookie(authentication.CookieName); err == nil {
+		session = cookie.Value
+	}
+
+	token, err := newCsrfToken(session, config.FromContext(r.Context()).Authentication.JwtSecret)
+	if err != nil {
+		s.internalErrorResponse(w, r, "Failed to create CSRF token", err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.Header().Set("Cache-Control", "no-store")
+	s.respond(w, r, CsrfTokenResponse{Token: token})
 }
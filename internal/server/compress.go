@@ -0,0 +1,281 @@
+package server
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go-chi-api/internal/conditional"
+	"go-chi-api/internal/config"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compresses a response body. Encoders are reused with Reset
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// Creates an encoder for each content coding that may be configured
+var encoders = map[string]func() encoder{
+	"gzip": func() encoder {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	},
+	"br": func() encoder {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	},
+	"zstd": func() encoder {
+		// RFC 9659 only requires clients to support windows of up to 8 MiB
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(8<<20))
+		return w
+	},
+}
+
+// Compresses response bodies with the encoding most preferred by the request's
+// Accept-Encoding header, of those configured. Bodies smaller than the minimum
+// size or of other content types are sent as they are
+func (s *Server) useCompression(cfg config.Compression) func(http.Handler) http.Handler {
+	pools := make(map[string]*sync.Pool, len(cfg.Encodings))
+	for _, encoding := range cfg.Encodings {
+		newEncoder := encoders[encoding]
+		pools[encoding] = &sync.Pool{New: func() any { return newEncoder() }}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), cfg.Encodings)
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       encoding,
+				pool:           pools[encoding],
+				minSize:        cfg.MinSize,
+				contentTypes:   cfg.ContentTypes,
+			}
+			next.ServeHTTP(cw, r)
+			if err := cw.close(); err != nil {
+				s.logger.DebugContext(r.Context(), "Failed to compress response", "error", err)
+			}
+		})
+	}
+}
+
+// Returns the most preferred of the offered encodings in an Accept-Encoding
+// header, breaking ties by the order offered, or "" if none is acceptable
+func negotiateEncoding(acceptEncoding string, offered []string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if coding == "" {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+
+		qualities[strings.ToLower(strings.TrimSpace(coding))] = quality
+	}
+
+	best, bestQuality := "", 0.0
+	for _, encoding := range offered {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality = qualities["*"]
+		}
+
+		if quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+
+	return best
+}
+
+// Holds back the start of the body until it is known whether it is large
+// enough to compress. The header is written once that is decided, without the
+// handler's Content-Length if the body is compressed
+type compressWriter struct {
+	http.ResponseWriter
+	encoding     string
+	pool         *sync.Pool
+	minSize      int
+	contentTypes []string
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder encoder
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	// Informational responses precede the real one, so are sent immediately
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if !w.decided {
+		compressible := w.compressible()
+		if compressible && len(w.buf)+len(p) < w.minSize {
+			w.buf = append(w.buf, p...)
+			return len(p), nil
+		}
+
+		if err := w.decide(compressible); err != nil {
+			return 0, err
+		}
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Write(p)
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+// Sends what has been written so far. A body flushed before reaching the
+// minimum size is compressed anyway, as it is being streamed and its final
+// size is unknown
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+
+		if err := w.decide(w.compressible()); err != nil {
+			return
+		}
+	}
+
+	if w.encoder != nil {
+		if err := w.encoder.Flush(); err != nil {
+			return
+		}
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Reports whether the response should be compressed, judging by its status
+// and headers. The size of the body is judged as it is written
+func (w *compressWriter) compressible() bool {
+	if w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	if length, err := strconv.Atoi(h.Get("Content-Length")); err == nil && length < w.minSize {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range w.contentTypes {
+		if allowed == mediaType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Writes the header, then anything buffered, compressing the body from then
+// on if compress is set
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	if compress {
+		h := w.Header()
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		h.Set("Content-Encoding", w.encoding)
+
+		// The compressed body is a different representation, so cannot share
+		// a strong validator with the uncompressed one
+		if etag := h.Get("ETag"); etag != "" {
+			h.Set("ETag", conditional.Weak(etag))
+		}
+
+		w.encoder = w.pool.Get().(encoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if w.encoder != nil {
+		_, err := w.encoder.Write(buf)
+		return err
+	}
+
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// Sends a body too small to have been compressed, or finishes compressing it
+func (w *compressWriter) close() error {
+	if !w.decided {
+		// Nothing was written, so net/http responds as usual
+		if w.status == 0 {
+			return nil
+		}
+
+		return w.decide(false)
+	}
+
+	if w.encoder == nil {
+		return nil
+	}
+
+	err := w.encoder.Close()
+	w.encoder.Reset(nil)
+	w.pool.Put(w.encoder)
+	w.encoder = nil
+
+	return err
+}
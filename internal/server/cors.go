@@ -0,0 +1,111 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go-chi-api/internal/config"
+)
+
+var ErrCorsCredentialsFromAnyOrigin = errors.New("CORS credentials cannot be allowed from any origin")
+
+// Checks the CORS policy of each group is safe to serve. Reloaded policies are
+// checked when the configuration is validated
+func checkCors(cfg config.Cors) error {
+	for _, group := range []string{"api", "docs"} {
+		policy := cfg.Policy(group)
+
+		// A cookie would be sent by every site the user visits
+		if policy.AllowCredentials && slices.Contains(policy.AllowedOrigins, "*") {
+			return ErrCorsCredentialsFromAnyOrigin
+		}
+	}
+
+	return nil
+}
+
+// Allows browsers on the origins allowed to the named group of routes by the
+// request's configuration snapshot to call them, answering preflight requests
+// and adding the CORS headers to the responses to them. Requests from other
+// origins are handled as usual, without the headers, so browsers do not let
+// the page read the response
+func (s *Server) useCors(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := config.FromContext(r.Context()).Cors.Policy(group)
+			if len(cfg.AllowedOrigins) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" || !originAllowed(cfg.AllowedOrigins, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Set("Access-Control-Allow-Origin", origin)
+			if cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if len(cfg.ExposedHeaders) > 0 {
+					h.Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+			if len(cfg.AllowedHeaders) > 0 {
+				h.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+			}
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// Reports whether origin matches one of the allowed origins. An allowed
+// origin of * matches any origin, and one containing * matches any
+// subdomains in its place, so https://*.example.com matches
+// https://app.example.com but not https://example.com
+func originAllowed(allowed []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if pattern == "*" || pattern == origin {
+			return true
+		}
+
+		prefix, suffix, ok := strings.Cut(pattern, "*")
+		if !ok || len(origin) <= len(prefix)+len(suffix) {
+			continue
+		}
+
+		if !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+			continue
+		}
+
+		// Only whole host labels may be matched, not a path, port or user
+		subdomains := origin[len(prefix) : len(origin)-len(suffix)]
+		if !strings.ContainsAny(subdomains, "/:@") && !strings.HasPrefix(subdomains, ".") && !strings.HasSuffix(subdomains, ".") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go-chi-api/internal/authentication"
+	"go-chi-api/internal/config"
+	"go-chi-api/internal/problem"
+)
+
+const (
+	// The cookie and header a CSRF token is submitted in. Both must hold
+	// the same token
+	csrfCookieName = "csrf_token"
+	csrfHeaderName = "X-CSRF-Token"
+
+	csrfRandomBytes = 32
+)
+
+// Protects cookie authenticated requests that change state from cross-site
+// request forgery. Unsafe requests carrying the authentication cookie must
+// come from the API's own origin or an origin allowed by CORS, judged by
+// Origin, or Referer if there is no Origin, and submit a CSRF token from
+// GET /auth/csrf in both the X-CSRF-Token header and its cookie, whatever
+// other credentials they carry, as only the cookie is verified. Browsers do
+// not attach other credentials by themselves, so requests without the cookie
+// are accepted from any origin
+func (s *Server) useCsrf(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, err := r.Cookie(authentication.CookieName)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cfg := config.FromContext(r.Context())
+		if !requestOriginAllowed(r, cfg.Cors.Api.AllowedOrigins) {
+			problem.Write(w, r, problem.OriginNotAllowed.New("Requests that change state are not accepted from this origin"))
+			return
+		}
+
+		token := r.Header.Get(csrfHeaderName)
+		if token == "" {
+			problem.Write(w, r, problem.CsrfTokenInvalid.New("The "+csrfHeaderName+" header is required"))
+			return
+		}
+
+		cookie, err := r.Cookie(csrfCookieName)
+		if err != nil || !hmac.Equal([]byte(cookie.Value), []byte(token)) || !csrfTokenValid(token, session.Value, cfg.Authentication.JwtSecret) {
+			problem.Write(w, r, problem.CsrfTokenInvalid.New("The CSRF token is invalid or was issued for another session"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Reports whether a request comes from the API's own origin, with the same
+// scheme and host, or one allowed by CORS. Requests with neither Origin nor
+// Referer are allowed, as they are not made by browsers, or are made by
+// browsers hiding where they come from
+func requestOriginAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		referer := r.Header.Get("Referer")
+		if referer == "" {
+			return true
+		}
+		origin = referer
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	if strings.EqualFold(u.Scheme, requestScheme(r)) && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	return originAllowed(allowed, u.Scheme+"://"+u.Host)
+}
+
+// Returns the scheme the client made the request with, which a trusted proxy
+// terminating TLS reports with X-Forwarded-Proto
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		return "https"
+	}
+
+	return "http"
+}
+
+// Creates a token bound to the session it is issued for, so a token issued to
+// one session cannot be planted in another. A token is a random value and a
+// signature of it with the session
+func newCsrfToken(session string, secret string) (string, error) {
+	random := make([]byte, csrfRandomBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(random)
+	return encoded + "." + csrfSignature(encoded, session, secret), nil
+}
+
+func csrfTokenValid(token string, session string, secret string) bool {
+	random, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(csrfSignature(random, session, secret)))
+}
+
+func csrfSignature(random string, session string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("csrf\x00" + random + "\x00" + session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-chi-api/internal/codec"
+	"go-chi-api/internal/problem"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type (
+	decodeOptions struct {
+		maxBytes              int64
+		disallowUnknownFields bool
+	}
+
+	// Changes how decodeBody decodes a request body
+	DecodeOption func(*decodeOptions)
+)
+
+// Limits the request body to n bytes instead of the configured maximum
+func WithMaxBytes(n int64) DecodeOption {
+	return func(o *decodeOptions) {
+		o.maxBytes = n
+	}
+}
+
+// Rejects request bodies with fields the request type does not have
+func DisallowUnknownFields() DecodeOption {
+	return func(o *decodeOptions) {
+		o.disallowUnknownFields = true
+	}
+}
+
+// Decodes a single value from the request body into a T, in the format given
+// by its Content-Type, then validates it. If either fails, this writes a
+// problem describing why into w and returns an error
+func decodeBody[T any](s *Server, w http.ResponseWriter, r *http.Request, opts ...DecodeOption) (T, error) {
+	var v T
+	options := decodeOptions{maxBytes: s.config.Load().Server.MaxBodyBytes}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	c, ok := s.codecs.ForContentType(r.Header.Get("Content-Type"))
+	if !ok {
+		problem.Write(w, r, problem.UnsupportedMediaType.New("Expected Content-Type of "+describeMediaTypes(s.codecs.MediaTypes())))
+		return v, ErrUnsupportedMediaType
+	}
+
+	if r.ContentLength > options.maxBytes {
+		problem.Write(w, r, bodyTooLargeProblem(options.maxBytes))
+		return v, ErrBodyTooLarge
+	}
+
+	body := http.MaxBytesReader(w, r.Body, options.maxBytes)
+	if err := c.Decode(body, &v, codec.DecodeOptions{DisallowUnknownFields: options.disallowUnknownFields}); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			problem.Write(w, r, bodyTooLargeProblem(maxBytesError.Limit))
+			return v, ErrBodyTooLarge
+		}
+
+		s.logger.DebugContext(r.Context(), "Failed to decode request body", "error", err)
+		var decodeError *codec.DecodeError
+		if !errors.As(err, &decodeError) {
+			problem.Write(w, r, problem.MalformedBody.New("The request body could not be read"))
+			return v, ErrMalformedBody
+		}
+
+		var fieldErrors []problem.FieldError
+		if decodeError.Field != nil {
+			fieldErrors = append(fieldErrors, *decodeError.Field)
+		}
+		problem.Write(w, r, problem.MalformedBody.New(decodeError.Detail, fieldErrors...))
+		return v, ErrMalformedBody
+	}
+
+	if err := s.validate.Struct(v); err != nil {
+		var validationErrors validator.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			s.internalErrorResponse(w, r, "Failed to validate request body", err)
+			return v, err
+		}
+
+		trans := s.validate.Translator(r.Header.Get("Accept-Language"))
+		fieldErrors := s.validate.FieldErrors(validationErrors, trans)
+		fields := make([]string, len(fieldErrors))
+		for i, fieldError := range fieldErrors {
+			fields[i] = fieldError.Pointer
+		}
+		s.logger.DebugContext(r.Context(), "Request body failed validation", "fields", fields)
+		w.Header().Add("Vary", "Accept-Language")
+		w.Header().Set("Content-Language", strings.ReplaceAll(trans.Locale(), "_", "-"))
+		problem.Write(w, r, problem.ValidationFailed.New("The request body failed validation", fieldErrors...))
+		return v, ErrValidationFailed
+	}
+
+	return v, nil
+}
+
+func bodyTooLargeProblem(limit int64) *problem.Problem {
+	return problem.ContentTooLarge.New(fmt.Sprintf("The request body must not exceed %d bytes", limit))
+}
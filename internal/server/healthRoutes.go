@@ -2,7 +2,8 @@ package server
 
 import (
 	"net/http"
-	"time"
+
+	"go-chi-api/internal/health"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -10,14 +11,19 @@ import (
 func (s *Server) healthRouter(r chi.Router) {
 	r.Route("/health", func(r chi.Router) {
 		r.Get("/", s.healthHandler)
+		r.Get("/live", s.livenessHandler)
+		r.Get("/ready", s.readinessHandler)
+		r.Get("/startup", s.startupHandler)
 	})
 }
 
 type HealthCheckInfo struct {
-	Key         string        `json:"key" example:"Database"`
-	Status      string        `json:"status" example:"Healthy"`
-	Description string        `json:"description" example:"Pinged DB"`
-	Duration    *JsonDuration `json:"duration" swaggertype:"string" example:"00:00:01.123456"`
+	Key         string         `json:"key" example:"Database"`
+	Status      string         `json:"status" example:"Healthy"`
+	Required    bool           `json:"required" example:"true"`
+	Description string         `json:"description" example:"Pinged DB"`
+	Duration    *JsonDuration  `json:"duration" swaggertype:"string" example:"00:00:01.123456"`
+	Data        map[string]any `json:"data,omitempty"`
 }
 
 type HealthCheckResponse struct {
@@ -26,43 +32,158 @@ type HealthCheckResponse struct {
 	Info     []HealthCheckInfo `json:"info"`
 }
 
+// Registers the checks of the server's own dependencies
+func (s *Server) registerHealthChecks() error {
+	return s.health.Register(health.Check{
+		Name:     "Database",
+		Required: true,
+		Checker:  health.CheckerFunc(s.db.Health),
+	})
+}
+
 // Health
 // @Summary Healthcheck
-// @Description Determine health of the API
+// @Description Determine health of the API and each of its dependencies. A failing optional dependency degrades the API, while a failing required dependency makes it unhealthy. The details of each dependency are only reported by the admin listener
 // @Tags health
-// @Produce json
+// @Produce json,application/cbor,application/msgpack
 // @Success 200 {object} server.HealthCheckResponse
+// @Failure 406 {object} problem.Problem
+// @Failure 503 {object} server.HealthCheckResponse
 // @Router /v1/health [get]
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	dbStart := time.Now()
-	dbHealthy, dbMessage := s.db.Health()
-
-	dbHealthInfo := HealthCheckInfo{
-		Key:         "Database",
-		Status:      getHealthStatus(dbHealthy, true),
-		Description: dbMessage,
-		Duration:    &JsonDuration{time.Now().Sub(dbStart)},
+	report := s.health.Run(r.Context())
+	s.healthResponse(w, r, report.Status != health.Unhealthy, s.publicHealthCheckResponse(r, report))
+}
+
+// Reports every check as healthHandler does, along with the details of each,
+// such as the database's version and pending migrations. These would tell an
+// attacker too much, so are only served by the admin listener
+func (s *Server) adminHealthHandler(w http.ResponseWriter, r *http.Request) {
+	report := s.health.Run(r.Context())
+	s.healthResponse(w, r, report.Status != health.Unhealthy, newHealthCheckResponse(report, true))
+}
+
+// Liveness
+// @Summary Liveness probe
+// @Description Succeeds while the process is able to serve requests, regardless of its dependencies
+// @Tags health
+// @Produce json,application/cbor,application/msgpack
+// @Success 200 {object} server.HealthCheckResponse
+// @Failure 406 {object} problem.Problem
+// @Router /v1/health/live [get]
+func (s *Server) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	s.healthResponse(w, r, true, HealthCheckResponse{
+		Status:   string(health.Healthy),
+		Duration: &JsonDuration{},
+		Info:     []HealthCheckInfo{},
+	})
+}
+
+// Readiness
+// @Summary Readiness probe
+// @Description Succeeds while every required dependency is healthy. Fails once the server has begun shutting down, so load balancers stop routing to it
+// @Tags health
+// @Produce json,application/cbor,application/msgpack
+// @Success 200 {object} server.HealthCheckResponse
+// @Failure 406 {object} problem.Problem
+// @Failure 503 {object} server.HealthCheckResponse
+// @Router /v1/health/ready [get]
+func (s *Server) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	if s.health.Draining() {
+		s.healthResponse(w, r, false, HealthCheckResponse{
+			Status:   string(health.Unhealthy),
+			Duration: &JsonDuration{},
+			Info: []HealthCheckInfo{{
+				Key:         "Server",
+				Status:      string(health.Unhealthy),
+				Required:    true,
+				Description: "Shutting down",
+				Duration:    &JsonDuration{},
+			}},
+		})
+		return
 	}
 
-	var info []HealthCheckInfo
-	response := HealthCheckResponse{
-		Status:   getHealthStatus(dbHealthy, true),
-		Duration: &JsonDuration{time.Now().Sub(start)},
-		Info:     append(info, dbHealthInfo),
+	report := s.health.Run(r.Context())
+	s.healthResponse(w, r, report.Status != health.Unhealthy, s.publicHealthCheckResponse(r, report))
+}
+
+// Startup
+// @Summary Startup probe
+// @Description Fails until every required dependency has been healthy at least once, then always succeeds
+// @Tags health
+// @Produce json,application/cbor,application/msgpack
+// @Success 200 {object} server.HealthCheckResponse
+// @Failure 406 {object} problem.Problem
+// @Failure 503 {object} server.HealthCheckResponse
+// @Router /v1/health/startup [get]
+func (s *Server) startupHandler(w http.ResponseWriter, r *http.Request) {
+	if s.health.Started() {
+		s.healthResponse(w, r, true, HealthCheckResponse{
+			Status:   string(health.Healthy),
+			Duration: &JsonDuration{},
+			Info:     []HealthCheckInfo{},
+		})
+		return
 	}
 
-	s.jsonResponse(w, response)
+	report := s.health.Run(r.Context())
+	s.healthResponse(w, r, s.health.Started(), s.publicHealthCheckResponse(r, report))
 }
 
-func getHealthStatus(requiredDeps bool, optionalDeps bool) string {
-	if !requiredDeps {
-		return "Unhealthy"
+// Writes response with 200 OK if passing, otherwise 503 Service Unavailable
+func (s *Server) healthResponse(w http.ResponseWriter, r *http.Request, passing bool, response HealthCheckResponse) {
+	status := http.StatusOK
+	if !passing {
+		status = http.StatusServiceUnavailable
 	}
 
-	if !optionalDeps {
-		return "Degraded"
+	w.Header().Set("Cache-Control", "no-store")
+	s.respondWithStatus(w, r, status, response)
+}
+
+// Converts report to a response for the public listener, logging why each
+// failing check failed, since the response only says that it did
+func (s *Server) publicHealthCheckResponse(r *http.Request, report health.Report) HealthCheckResponse {
+	for _, check := range report.Checks {
+		if check.Status != health.Healthy {
+			s.logger.WarnContext(r.Context(), "Health check failed",
+				"check", check.Name, "status", check.Status, "description", check.Description)
+		}
 	}
 
-	return "Healthy"
+	return newHealthCheckResponse(report, false)
+}
+
+// Converts report to a response. Only a detailed response includes the data
+// of each check and why it failed, as driver errors and the like could tell an
+// attacker about the network
+func newHealthCheckResponse(report health.Report, detailed bool) HealthCheckResponse {
+	info := make([]HealthCheckInfo, len(report.Checks))
+	for i, check := range report.Checks {
+		info[i] = HealthCheckInfo{
+			Key:         check.Name,
+			Status:      string(check.Status),
+			Required:    check.Required,
+			Description: check.Description,
+			Duration:    &JsonDuration{check.Duration},
+		}
+		if detailed {
+			info[i].Data = check.Data
+			continue
+		}
+
+		switch check.Status {
+		case health.Unhealthy:
+			info[i].Description = check.Name + " unavailable"
+		case health.Degraded:
+			info[i].Description = check.Name + " degraded"
+		}
+	}
+
+	return HealthCheckResponse{
+		Status:   string(report.Status),
+		Duration: &JsonDuration{report.Duration},
+		Info:     info,
+	}
 }
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go-chi-api/internal/authentication"
+	"go-chi-api/internal/config"
+	"go-chi-api/internal/idempotency"
+	"go-chi-api/internal/problem"
+)
+
+// The longest Idempotency-Key accepted
+const maxIdempotencyKeyLength = 255
+
+// Replays the first response to a request with an Idempotency-Key for any
+// retry of it, so that retrying a request which changes state is safe. Keys
+// are scoped to the user and route, and a key may only be reused for the same
+// request. Responses are only kept if the request did not fail with a 5xx, so
+// those can be retried. Requests without a key are handled as usual
+func (s *Server) useIdempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := idempotencyKey(r.Header.Get("Idempotency-Key"))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if key == "" || len(key) > maxIdempotencyKeyLength {
+			problem.Write(w, r, problem.BadRequest.New(fmt.Sprintf("Idempotency-Key must be between 1 and %d characters", maxIdempotencyKeyLength)))
+			return
+		}
+
+		cfg := config.FromContext(r.Context())
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.Server.MaxBodyBytes))
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				problem.Write(w, r, bodyTooLargeProblem(maxBytesError.Limit))
+				return
+			}
+
+			problem.Write(w, r, problem.MalformedBody.New("The request body could not be read"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := idempotencyScope(r, key)
+		fingerprint := requestFingerprint(r, body, cfg.Authentication.JwtSecret)
+		now := s.now()
+		record, err := s.idempotency.Claim(r.Context(), scope, fingerprint, now, now.Add(cfg.Idempotency.TTL))
+		if err != nil {
+			s.internalErrorResponse(w, r, "Failed to claim idempotency key", err)
+			return
+		}
+
+		if record != nil {
+			switch {
+			case record.Fingerprint != fingerprint:
+				problem.Write(w, r, problem.IdempotencyKeyReused.New("The Idempotency-Key was already used for a different request"))
+			case record.Response == nil:
+				w.Header().Set("Retry-After", "1")
+				problem.Write(w, r, problem.IdempotencyKeyInUse.New("A request with this Idempotency-Key is still being handled"))
+			default:
+				replayResponse(w, record.Response)
+			}
+			return
+		}
+
+		// Released unless a response is saved, including if the handler panics
+		saved := false
+		defer func() {
+			if !saved {
+				if err := s.idempotency.Release(context.WithoutCancel(r.Context()), scope); err != nil {
+					s.logger.ErrorContext(r.Context(), "Failed to release idempotency key", "error", err)
+				}
+			}
+		}()
+
+		rec := &recordingWriter{ResponseWriter: w, header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status >= http.StatusInternalServerError {
+			return
+		}
+
+		header := rec.header.Clone()
+		header.Del("Set-Cookie")
+		response := idempotency.Response{Status: rec.status, Header: header, Body: rec.body.Bytes()}
+		if err := s.idempotency.Complete(context.WithoutCancel(r.Context()), scope, response); err != nil {
+			s.logger.ErrorContext(r.Context(), "Failed to save idempotent response", "error", err)
+			return
+		}
+		saved = true
+	})
+}
+
+// Parses an Idempotency-Key header, which is a structured field string but is
+// also accepted unquoted. ok is false if the header is missing
+func idempotencyKey(header string) (key string, ok bool) {
+	if header == "" {
+		return "", false
+	}
+
+	if len(header) >= 2 && strings.HasPrefix(header, `"`) && strings.HasSuffix(header, `"`) {
+		header = header[1 : len(header)-1]
+	}
+
+	return strings.TrimSpace(header), true
+}
+
+// Combines the key with the user making the request and the route, so keys
+// chosen by different clients never collide. Anonymous clients are told apart
+// by their address, so one cannot replay another's response
+func idempotencyScope(r *http.Request, key string) string {
+	user := "ip:" + clientIp(r)
+	if id, ok := r.Context().Value(authentication.ContextValueUserId).(int64); ok {
+		user = fmt.Sprint(id)
+	}
+
+	sum := sha256.Sum256([]byte(user + "\x00" + r.Method + " " + r.URL.Path + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Identifies the content of a request, which a retry must repeat. It is keyed
+// by secret, so that stored fingerprints cannot be used to guess what was
+// sent, such as a password
+func requestFingerprint(r *http.Request, body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(r.Header.Get("Content-Type") + "\x00"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func replayResponse(w http.ResponseWriter, response *idempotency.Response) {
+	mergeHeader(w.Header(), response.Header)
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(response.Status)
+	w.Write(response.Body)
+}
+
+// Adds the headers written by a handler to those already set by earlier
+// middleware, replacing any of the same name except Vary, which both may add to
+func mergeHeader(dst http.Header, src http.Header) {
+	for name, values := range src {
+		if name == "Vary" {
+			dst[name] = append(dst[name], values...)
+			continue
+		}
+		dst[name] = values
+	}
+}
+
+// Records the status, headers and body written through it. The handler's
+// headers are kept apart from those set by earlier middleware, such as
+// X-Request-Id, RateLimit-* and Content-Encoding, which describe this request
+// rather than the response to replay, and are only merged into the response
+// when its header is written
+type recordingWriter struct {
+	http.ResponseWriter
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *recordingWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+		mergeHeader(w.ResponseWriter.Header(), w.header)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
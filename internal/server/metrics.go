@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+
+	telemetry "go-chi-api/internal/otel"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	apimetric "go.opentelemetry.io/otel/metric"
+)
+
+const instrumentationName = "go-chi-api/internal/server"
+
+// Results and reasons recorded for registrations and logins. Successful
+// attempts have no reason, except for logins whose password must be rehashed
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+
+	reasonUnknownUser  = "unknown_user"
+	reasonBadPassword  = "bad_password"
+	reasonRehashNeeded = "rehash_needed"
+	reasonRejected     = "rejected"
+	reasonError        = "error"
+)
+
+// Domain metrics recorded by handlers
+type metrics struct {
+	registrations apimetric.Int64Counter
+	logins        apimetric.Int64Counter
+}
+
+func newMetrics() (*metrics, error) {
+	meter := otel.Meter(instrumentationName)
+
+	registrations, err := meter.Int64Counter(
+		telemetry.MetricAuthRegistrations,
+		apimetric.WithDescription("User registration attempts, by result and reason"),
+		apimetric.WithUnit("{registration}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	logins, err := meter.Int64Counter(
+		telemetry.MetricAuthLogins,
+		apimetric.WithDescription("Login attempts, by result and reason"),
+		apimetric.WithUnit("{login}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metrics{registrations: registrations, logins: logins}, nil
+}
+
+func (m *metrics) recordRegistration(ctx context.Context, result string, reason string) {
+	m.registrations.Add(ctx, 1, apimetric.WithAttributes(resultAttrs(result, reason)...))
+}
+
+func (m *metrics) recordLogin(ctx context.Context, result string, reason string) {
+	m.logins.Add(ctx, 1, apimetric.WithAttributes(resultAttrs(result, reason)...))
+}
+
+func resultAttrs(result string, reason string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("result", result)}
+	if reason != "" {
+		attrs = append(attrs, attribute.String("reason", reason))
+	}
+
+	return attrs
+}
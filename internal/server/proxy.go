@@ -0,0 +1,108 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Parses the CIDRs of the proxies whose forwarding headers are believed
+func parseTrustedProxies(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, len(cidrs))
+	for i, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		prefixes[i] = prefix.Masked()
+	}
+
+	return prefixes, nil
+}
+
+// Identifies the client by the address the request came from or, when that is
+// a trusted proxy, by the address the proxy forwarded the request for. The
+// forwarding headers of requests from anywhere else are removed, so clients
+// cannot choose the address they are identified, and rate limited, by, nor
+// claim with X-Forwarded-Proto to have used HTTPS
+func (s *Server) useClientIp(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.trustedProxy(r.RemoteAddr) {
+			r.Header.Del("X-Forwarded-For")
+			r.Header.Del("X-Real-IP")
+			r.Header.Del("X-Forwarded-Proto")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if ip := s.forwardedFor(r); ip != "" {
+			r.RemoteAddr = ip
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Returns the client a trusted proxy forwarded the request for. Proxies append
+// the address each received the request from to X-Forwarded-For, so it is the
+// last address not of a trusted proxy, as any before it may have been made up
+// by the client. X-Real-IP is used when there is no X-Forwarded-For
+func (s *Server) forwardedFor(r *http.Request) string {
+	var addrs []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		addrs = append(addrs, strings.Split(header, ",")...)
+	}
+
+	if len(addrs) == 0 {
+		if ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return ip.Unmap().String()
+		}
+		return ""
+	}
+
+	var client netip.Addr
+	for i := len(addrs) - 1; i >= 0; i-- {
+		ip, err := netip.ParseAddr(strings.TrimSpace(addrs[i]))
+		if err != nil {
+			break
+		}
+
+		client = ip.Unmap()
+		if !s.trustedAddr(client) {
+			break
+		}
+	}
+
+	if !client.IsValid() {
+		return ""
+	}
+
+	return client.String()
+}
+
+// Reports whether the request came from a trusted proxy, given its remote
+// address
+func (s *Server) trustedProxy(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+
+	return s.trustedAddr(ip.Unmap())
+}
+
+func (s *Server) trustedAddr(ip netip.Addr) bool {
+	for _, prefix := range s.trustedProxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-chi-api/internal/authentication"
+	"go-chi-api/internal/config"
+	"go-chi-api/internal/problem"
+	"go-chi-api/internal/ratelimit"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Limits the requests made to the routes of the named group by its policy in
+// the request's configuration snapshot, so a reload takes effect immediately,
+// responding with a 429 problem to those over the limit. Every response
+// describes the limit with RateLimit headers. Requests are allowed if the
+// store fails, so that an outage of it does not take down the API
+func (s *Server) useRateLimit(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy := config.FromContext(r.Context()).RateLimit.Policy(group)
+			if policy.Requests == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			algorithm := ratelimit.SlidingWindow
+			if policy.Algorithm == "token_bucket" {
+				algorithm = ratelimit.TokenBucket
+			}
+
+			// The algorithms keep different state, so changing the algorithm
+			// starts counting afresh
+			limiter := ratelimit.New(algorithm, s.rateLimits, ratelimit.Limit{Requests: policy.Requests, Period: policy.Period})
+			key := group + "\x00" + policy.Algorithm + "\x00" + rateLimitKey(r, policy.KeyBy)
+			result, err := limiter.Take(r.Context(), key, s.now())
+			if err != nil {
+				s.logger.ErrorContext(r.Context(), "Failed to apply rate limit", "group", group, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Requests, int(math.Ceil(policy.Period.Seconds()))))
+			h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			h.Set("RateLimit-Reset", wholeSeconds(result.Reset))
+
+			if !result.Allowed {
+				h.Set("Retry-After", wholeSeconds(result.RetryAfter))
+				problem.Write(w, r, problem.TooManyRequests.New("Too many requests were made, retry after "+wholeSeconds(result.RetryAfter)+" seconds"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Combines the parts of the request named by keyBy into the key its requests
+// are counted by
+func rateLimitKey(r *http.Request, keyBy []string) string {
+	parts := make([]string, len(keyBy))
+	for i, by := range keyBy {
+		switch by {
+		case "user":
+			if id, ok := r.Context().Value(authentication.ContextValueUserId).(int64); ok {
+				parts[i] = "user:" + strconv.FormatInt(id, 10)
+				continue
+			}
+			parts[i] = "ip:" + clientIp(r)
+		case "api_key":
+			// Hashed, so keys are not kept in the store
+			if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+				sum := sha256.Sum256([]byte(apiKey))
+				parts[i] = "api_key:" + hex.EncodeToString(sum[:])
+				continue
+			}
+			parts[i] = "ip:" + clientIp(r)
+		case "route":
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			parts[i] = "route:" + r.Method + " " + route
+		default:
+			parts[i] = "ip:" + clientIp(r)
+		}
+	}
+
+	return strings.Join(parts, "\x00")
+}
+
+// Returns the client's address, which useClientIp has already taken from the
+// forwarding headers if the request came through a trusted proxy
+func clientIp(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+
+	return r.RemoteAddr
+}
+
+// Formats d as whole seconds, rounding up so clients never retry too early
+func wholeSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -1,110 +1,305 @@
 package server
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
-	"log"
+	"go-chi-api/internal/codec"
+	"go-chi-api/internal/conditional"
+	"go-chi-api/internal/config"
+	"go-chi-api/internal/logging"
+	"go-chi-api/internal/otel"
+	"go-chi-api/internal/problem"
+	"go-chi-api/internal/requestid"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-playground/validator/v10"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	ErrInvalidContentType = errors.New("Invalid Content-Type")
-	ErrInvalidJsonBody    = errors.New("Invalid JSON body")
-	ErrValidationFailed   = errors.New("JSON request failed validation")
+	ErrUnsupportedMediaType = errors.New("Unsupported Content-Type")
+	ErrMalformedBody        = errors.New("Malformed request body")
+	ErrBodyTooLarge         = errors.New("Request body too large")
+	ErrValidationFailed     = errors.New("Request body failed validation")
 )
 
-func (s *Server) RegisterRoutes() http.Handler {
+func (s *Server) RegisterRoutes() (http.Handler, error) {
+	useTelemetry, err := otel.NewHTTPMiddleware()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := s.config.Load()
+	if err := checkCors(cfg.Cors); err != nil {
+		return nil, err
+	}
+
 	r := chi.NewRouter()
-	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(time.Minute))
+	r.Use(s.useClientIp)
+	r.Use(useTelemetry)
+	r.Use(s.useConfigSnapshot)
+	r.Use(s.useRequestId)
+	r.Use(s.useRequestLogging)
+	r.Use(s.useSecurityHeaders(cfg.Security, cfg.Security.ApiContentSecurityPolicy))
+	r.Use(s.useCompression(cfg.Compression))
+	r.Use(s.useRecovery)
+	r.Use(s.useTimeout(cfg.Server.RequestTimeout))
+
+	// Set before any routes are mounted, as sub-routers copy them when mounted
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.NotFound.New(""))
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.MethodNotAllowed.New(r.Method+" is not supported by this resource"))
+	})
 
 	s.swaggerRouter(r)
 	r.Route("/v1.0", func(r chi.Router) {
-		r.Get("/", s.HelloWorldHandler)
+		r.Use(s.useCors("api"))
+		r.Use(s.useContentNegotiation)
+		r.Use(s.useCsrf)
+		r.With(s.useRateLimit("api")).Get("/", s.HelloWorldHandler)
 		s.healthRouter(r)
 		s.authRouter(r)
 	})
 
+	return r, nil
+}
+
+// Returns the handler for the admin listener, which serves operational
+// endpoints that should not be exposed alongside the API: the health checks in
+// full, and metrics if there is a handler for them
+func (s *Server) RegisterAdminRoutes(metrics http.Handler) http.Handler {
+	r := chi.NewRouter()
+	r.Use(s.useRecovery)
+	r.Get("/health", s.adminHealthHandler)
+	if metrics != nil {
+		r.Method(http.MethodGet, "/metrics", metrics)
+	}
+
 	return r
 }
 
-func (s *Server) badRequestResponse(w http.ResponseWriter, errors any) {
-	w.Header().Set("Content-Type", "application/problem+json")
-	w.WriteHeader(http.StatusBadRequest)
+// Attaches the configuration snapshot current at the start of the request, so
+// a reload part way through cannot change how the request is handled. It can
+// be retrieved with config.FromContext
+func (s *Server) useConfigSnapshot(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := config.NewContext(r.Context(), s.config.Load())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Identifies the request by the X-Request-Id it was sent with, if valid, or
+// a new id otherwise, returning it in the response and recording it on the
+// request's span. It can be retrieved with requestid.FromContext
+func (s *Server) useRequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New(r.Context())
+		}
 
-	log.Println("Errors", errors)
+		w.Header().Set(requestid.Header, id)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("request.id", id))
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
 
-	response := make(map[string]any)
-	response["type"] = "https://tools.ietf.org/html/rfc9110#section-15.5.1"
-	response["title"] = "Bad Request"
-	response["status"] = http.StatusBadRequest
-	response["errors"] = errors
+// Chooses the format of the response from the request's Accept header,
+// responding with a 406 problem if none is acceptable. It can be retrieved
+// with codec.FromContext
+func (s *Server) useContentNegotiation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		c, ok := s.codecs.Negotiate(r.Header.Get("Accept"))
+		if !ok {
+			problem.Write(w, r, problem.NotAcceptable.New("Responses can be given as "+describeMediaTypes(s.codecs.MediaTypes())))
+			return
+		}
 
-	jsonResponse, _ := json.Marshal(&response)
-	w.Write(jsonResponse)
+		next.ServeHTTP(w, r.WithContext(codec.NewContext(r.Context(), c)))
+	})
 }
 
-func (s *Server) jsonResponse(w http.ResponseWriter, response any) {
-	body, err := json.Marshal(response)
-	if err != nil {
-		log.Print(err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+// Lists media types as "a, b or c"
+func describeMediaTypes(mediaTypes []string) string {
+	if len(mediaTypes) < 2 {
+		return strings.Join(mediaTypes, "")
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
-	w.WriteHeader(http.StatusOK)
-	w.Write(body)
+	return strings.Join(mediaTypes[:len(mediaTypes)-1], ", ") + " or " + mediaTypes[len(mediaTypes)-1]
 }
 
-// Decodes JSON from the request body, writing the result into v. If an error
-// occurs, this writes the proper response into w
-func (s *Server) decodeJson(w http.ResponseWriter, r *http.Request, v any) error {
-	if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
-		s.badRequestResponse(w, "Expected Content-Type of application/json")
-		return ErrInvalidContentType
+// Recovers from panics in later handlers, logging the panic and responding
+// with a 500 problem if nothing has been written yet. http.ErrAbortHandler is
+// repanicked, as it is used to abort the response deliberately
+func (s *Server) useRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if rvr == http.ErrAbortHandler {
+				panic(rvr)
+			}
+
+			s.logger.ErrorContext(r.Context(), "Recovered from panic", "panic", rvr, "stack", string(debug.Stack()))
+			if ww.Status() == 0 {
+				problem.Write(ww, r, problem.InternalServerError.New(""))
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// Cancels the request's context after timeout, responding with a 504 problem
+// if the handler gives up without writing a response
+func (s *Server) useTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			r = r.WithContext(ctx)
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) && ww.Status() == 0 {
+				problem.Write(ww, r, problem.GatewayTimeout.New("The request took longer than "+timeout.String()))
+			}
+		})
 	}
+}
+
+// Logs the outcome of every request. Request scoped log attributes are
+// attached to the request context, which later middleware and handlers may add
+// to with logging.AddAttrs
+func (s *Server) useRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := s.now()
+		ctx := logging.NewContext(
+			r.Context(),
+			slog.String("request_id", requestid.FromContext(r.Context())),
+			slog.Any("route", routePattern{chi.RouteContext(r.Context())}),
+		)
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		s.logger.LogAttrs(
+			ctx,
+			level,
+			"Request completed",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Duration("duration", s.now().Sub(start)),
+		)
+	})
+}
 
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&v); err != nil || v == nil {
-		log.Println("Failed to decode", err)
-		s.badRequestResponse(w, err)
-		return ErrInvalidJsonBody
+// Logs as the pattern of the matched route, which is only known once routing
+// has completed
+type routePattern struct {
+	rctx *chi.Context
+}
+
+func (p routePattern) LogValue() slog.Value {
+	if p.rctx == nil {
+		return slog.StringValue("")
 	}
 
-	if err := s.validate.Struct(v); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-		s.badRequestResponse(w, validationErrors)
-		return ErrValidationFailed
+	return slog.StringValue(p.rctx.RoutePattern())
+}
+
+// Logs err, then responds with a 500 problem which does not disclose it
+func (s *Server) internalErrorResponse(w http.ResponseWriter, r *http.Request, message string, err error) {
+	s.logger.ErrorContext(r.Context(), message, "error", err)
+	problem.Write(w, r, problem.InternalServerError.New(""))
+}
+
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, response any) {
+	s.respondWithStatus(w, r, http.StatusOK, response)
+}
+
+// Writes response in the format negotiated for the request, or JSON if none was
+func (s *Server) respondWithStatus(w http.ResponseWriter, r *http.Request, status int, response any) {
+	c := codec.FromContext(r.Context())
+	body, err := c.Marshal(response)
+	if err != nil {
+		s.internalErrorResponse(w, r, "Failed to encode response", err)
+		return
 	}
 
-	return nil
+	// Successful reads carry validators, so clients can revalidate what they
+	// have cached. A handler may set its own ETag or Last-Modified first
+	if status == http.StatusOK && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		if w.Header().Get("ETag") == "" {
+			w.Header().Set("ETag", conditional.ETag(body))
+		}
+
+		lastModified, _ := http.ParseTime(w.Header().Get("Last-Modified"))
+		if !s.checkPreconditions(w, r, w.Header().Get("ETag"), lastModified) {
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", c.MediaTypes()[0])
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// Evaluates the request's preconditions against the validators of the
+// resource's current representation, responding with 304 or a 412 problem and
+// returning false if the request should go no further. Handlers changing a
+// resource call this before making the change, so a client can make it
+// conditional on the version it last saw with If-Match
+func (s *Server) checkPreconditions(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	switch conditional.Check(r, etag, lastModified) {
+	case conditional.NotModified:
+		w.WriteHeader(http.StatusNotModified)
+		return false
+	case conditional.PreconditionFailed:
+		problem.Write(w, r, problem.PreconditionFailed.New("The resource has changed since it was last retrieved"))
+		return false
+	default:
+		return true
+	}
 }
 
 // HelloWorld
 // @Summary Say hello!
 // @Description Hello, there!
 // @Tags hello
-// @Accept json
-// @Produce json
+// @Accept json,application/cbor,application/msgpack
+// @Produce json,application/cbor,application/msgpack
 // @Router /v1/ [get]
 func (s *Server) HelloWorldHandler(w http.ResponseWriter, r *http.Request) {
 	resp := make(map[string]string)
 	resp["message"] = "Hello World"
 
-	jsonResp, err := json.Marshal(resp)
-	if err != nil {
-		log.Fatalf("error handling JSON marshal. Err: %v", err)
-	}
-
-	_, _ = w.Write(jsonResp)
+	s.respond(w, r, resp)
 }
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"go-chi-api/internal/config"
+)
+
+// Adds the hardening headers to every response, with the Content-Security-
+// Policy given. Strict-Transport-Security is only sent on requests made over
+// HTTPS, directly or through a trusted proxy, as browsers ignore it otherwise.
+// useClientIp has removed X-Forwarded-Proto from requests sent by anyone else
+func (s *Server) useSecurityHeaders(cfg config.Security, contentSecurityPolicy string) func(http.Handler) http.Handler {
+	hsts := ""
+	if cfg.HstsMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(cfg.HstsMaxAge.Seconds()))
+		if cfg.HstsIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			if cfg.ReferrerPolicy != "" {
+				h.Set("Referrer-Policy", cfg.ReferrerPolicy)
+			}
+			if cfg.FrameOptions != "" {
+				h.Set("X-Frame-Options", cfg.FrameOptions)
+			}
+			if contentSecurityPolicy != "" {
+				h.Set("Content-Security-Policy", contentSecurityPolicy)
+			}
+			if hsts != "" && requestScheme(r) == "https" {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,103 +2,400 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
-	"log"
-	"math"
+	"log/slog"
+	"net"
 	"net/http"
-	"os"
+	"net/netip"
 	"strconv"
 	"time"
 
 	"go-chi-api/internal/authentication"
+	"go-chi-api/internal/codec"
+	"go-chi-api/internal/config"
 	"go-chi-api/internal/database"
+	"go-chi-api/internal/health"
+	"go-chi-api/internal/idempotency"
 	"go-chi-api/internal/otel"
+	"go-chi-api/internal/ratelimit"
+	"go-chi-api/internal/validation"
 
-	"github.com/go-playground/validator/v10"
-	_ "github.com/joho/godotenv/autoload"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 type Server struct {
-	server   *http.Server
-	port     int
-	db       database.Service
-	auth     authentication.Service
-	validate *validator.Validate
-	otel     otel.Service
+	server         *http.Server
+	admin          *http.Server
+	config         *config.Store
+	port           int
+	db             database.Service
+	ownsDb         bool
+	auth           authentication.Service
+	validate       *validation.Validator
+	codecs         *codec.Registry
+	idempotency    idempotency.Store
+	rateLimits     ratelimit.Store
+	trustedProxies []netip.Prefix
+	otel           otel.Service
+	ownsOtel       bool
+	health         *health.Registry
+	metrics        *metrics
+	logger         *slog.Logger
+	now            func() time.Time
 }
 
-func NewServer(ctx context.Context, serviceName string, serviceVersion string) (*Server, error) {
-	portStr := os.Getenv("PORT")
-	port, err := strconv.Atoi(portStr)
-	if err != nil || port > math.MaxUint16 {
-		log.Fatalf("Error: PORT is not a valid port (%s)", portStr)
+// Option overrides one of the dependencies NewServer would otherwise
+// construct from the configuration
+type Option func(*Server)
+
+// Sets the port the server listens on instead of the configured port
+func WithPort(port int) Option {
+	return func(s *Server) {
+		s.port = port
 	}
+}
 
-	otelService, err := otel.New(ctx, serviceName, serviceVersion)
-	if err != nil {
-		return nil, err
+func WithDatabase(db database.Service) Option {
+	return func(s *Server) {
+		s.db = db
+	}
+}
+
+func WithAuthentication(auth authentication.Service) Option {
+	return func(s *Server) {
+		s.auth = auth
+	}
+}
+
+// Sets where responses to requests with an Idempotency-Key are kept, instead
+// of the configured store
+func WithIdempotencyStore(store idempotency.Store) Option {
+	return func(s *Server) {
+		s.idempotency = store
 	}
+}
 
+// Sets where the requests counted by rate limits are kept, instead of the
+// configured store
+func WithRateLimitStore(store ratelimit.Store) Option {
+	return func(s *Server) {
+		s.rateLimits = store
+	}
+}
+
+func WithOtel(otel otel.Service) Option {
+	return func(s *Server) {
+		s.otel = otel
+	}
+}
+
+// Sets the source of the current time used by handlers
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// Creates a Server. Any dependency not provided through opts is constructed
+// from the configuration in store, returning an error if it cannot be
+func NewServer(ctx context.Context, store *config.Store, serviceVersion string, opts ...Option) (*Server, error) {
+	cfg := store.Load()
 	server := &Server{
-		port:     port,
-		db:       database.New(),
-		auth:     authentication.New(),
-		validate: validator.New(),
-		otel:     otelService,
+		config: store,
+		port:   cfg.Server.Port,
+		logger: slog.Default(),
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	if server.db == nil {
+		db, err := database.New(cfg.Database)
+		if err != nil {
+			return nil, err
+		}
+
+		server.db = db
+		server.ownsDb = true
+	}
+
+	if server.idempotency == nil {
+		switch cfg.Idempotency.Store {
+		case "memory":
+			server.idempotency = idempotency.NewMemoryStore()
+		default:
+			server.idempotency = server.db.IdempotencyStore()
+		}
+	}
+
+	if server.rateLimits == nil {
+		switch cfg.RateLimit.Store {
+		case "memory":
+			server.rateLimits = ratelimit.NewMemoryStore()
+		default:
+			server.rateLimits = server.db.RateLimitStore()
+		}
+	}
+
+	if server.auth == nil {
+		auth, err := authentication.New(cfg.Authentication)
+		if err != nil {
+			return nil, errors.Join(err, server.release(ctx))
+		}
+
+		server.auth = auth
+	}
+
+	if server.otel == nil {
+		otelService, err := otel.New(ctx, store, serviceVersion)
+		if err != nil {
+			return nil, errors.Join(err, server.release(ctx))
+		}
+
+		server.otel = otelService
+		server.ownsOtel = true
+	}
+
+	trustedProxies, err := parseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		return nil, errors.Join(err, server.release(ctx))
+	}
+
+	server.trustedProxies = trustedProxies
+
+	validate, err := validation.New()
+	if err != nil {
+		return nil, errors.Join(err, server.release(ctx))
+	}
+
+	server.validate = validate
+	server.codecs = codec.NewRegistry(codec.JSON, codec.CBOR, codec.MessagePack)
+
+	metrics, err := newMetrics()
+	if err != nil {
+		return nil, errors.Join(err, server.release(ctx))
+	}
+
+	server.metrics = metrics
+
+	server.health = health.NewRegistry(server.now, cfg.Health.Timeout, cfg.Health.CacheFor)
+	if err := server.registerHealthChecks(); err != nil {
+		return nil, errors.Join(err, server.release(ctx))
+	}
+
+	handler, err := server.RegisterRoutes()
+	if err != nil {
+		return nil, errors.Join(err, server.release(ctx))
+	}
+
+	tlsConfig, err := newTLSConfig(cfg.Server.TLS, server.logger)
+	if err != nil {
+		return nil, errors.Join(err, server.release(ctx))
+	}
+
+	// HTTP/2 is negotiated during the TLS handshake, so without TLS it must be
+	// recognised from the start of the connection instead
+	if tlsConfig == nil && cfg.Server.H2C {
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: cfg.Server.IdleTimeout})
 	}
 
 	// Declare Server config
 	server.server = &http.Server{
 		Addr:         fmt.Sprintf(":%d", server.port),
-		Handler:      server.RegisterRoutes(),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		Handler:      handler,
+		TLSConfig:    tlsConfig,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+	}
+
+	prometheus := cfg.Telemetry.Prometheus
+	server.admin = &http.Server{
+		Addr:         net.JoinHostPort(prometheus.Host, strconv.Itoa(prometheus.Port)),
+		Handler:      server.RegisterAdminRoutes(server.otel.MetricsHandler()),
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
 	}
 
 	return server, nil
 }
 
+// Releases what NewServer has acquired when it fails part way through. A
+// database or telemetry provided through WithDatabase or WithOtel belongs to
+// the caller, so is left open
+func (s *Server) release(ctx context.Context) error {
+	var err error
+	if s.ownsOtel {
+		err = s.otel.Shutdown(ctx)
+	}
+
+	if s.ownsDb {
+		err = errors.Join(err, s.db.Close())
+	}
+
+	return err
+}
+
+// Returns the fully routed handler served by ListenAndServe, suitable for use
+// with httptest
+func (s *Server) Handler() http.Handler {
+	return s.server.Handler
+}
+
+// Returns the handler served by the admin listener, suitable for use with
+// httptest
+func (s *Server) AdminHandler() http.Handler {
+	return s.admin.Handler
+}
+
+// Returns the TLS configuration ListenAndServe serves with, or nil if TLS is
+// disabled, suitable for use with httptest
+func (s *Server) TLSConfig() *tls.Config {
+	return s.server.TLSConfig
+}
+
+// Returns the registry of health checks reported by the health endpoints, with
+// which other subsystems may register their own checks
+func (s *Server) Health() *health.Registry {
+	return s.health
+}
+
+// Serves the API and the admin routes until Shutdown is called or either
+// listener fails
 func (s *Server) ListenAndServe() error {
-	log.Println(fmt.Sprintf("Starting server at %s", s.server.Addr))
-	return s.server.ListenAndServe()
+	errs := make(chan error, 2)
+	go func() {
+		if s.server.TLSConfig != nil {
+			s.logger.Info("Starting server with TLS", "address", s.server.Addr)
+			errs <- s.server.ListenAndServeTLS("", "")
+			return
+		}
+
+		s.logger.Info("Starting server", "address", s.server.Addr)
+		errs <- s.server.ListenAndServe()
+	}()
+
+	go func() {
+		s.logger.Info("Starting admin server", "address", s.admin.Addr)
+		errs <- s.admin.ListenAndServe()
+	}()
+
+	return <-errs
 }
 
+// Fails readiness checks, waits for the drain delay so load balancers stop
+// routing new requests here, then gracefully closes connections. Only the
+// database and telemetry NewServer created are closed
 func (s *Server) Shutdown() (err error) {
-	log.Println("Shutdown requested, gracefully closing connections. This may take a minute...")
-	stopCtx, cancelStopCtx := context.WithTimeout(context.Background(), time.Minute)
+	cfg := s.config.Load()
+	s.health.Drain()
+	if delay := cfg.Server.DrainDelay; delay > 0 {
+		s.logger.Info("Shutdown requested, draining before closing connections", "delay", delay)
+		time.Sleep(delay)
+	}
+
+	timeout := cfg.Server.ShutdownTimeout
+	s.logger.Info("Shutdown requested, gracefully closing connections", "timeout", timeout)
+	stopCtx, cancelStopCtx := context.WithTimeout(context.Background(), timeout)
 	defer cancelStopCtx()
+	if s.ownsOtel {
+		defer func() {
+			err = errors.Join(err, s.otel.Shutdown(context.Background()))
+		}()
+	}
+
+	if s.ownsDb {
+		defer func() {
+			err = errors.Join(err, s.db.Close())
+		}()
+	}
+
 	defer func() {
-		err = errors.Join(err, s.otel.Shutdown(context.Background()))
+		err = errors.Join(err, s.admin.Shutdown(stopCtx))
 	}()
 
 	err = s.server.Shutdown(stopCtx)
 	return
 }
 
+// Encodes as an RFC 3339 timestamp in UTC to the second, or null if unset, in
+// every wire format. The marshalers have value receivers so they take
+// precedence over those promoted from time.Time
 type JsonTime struct {
 	*time.Time
 }
 
-func (t *JsonTime) MarshalJSON() ([]byte, error) {
+// Reports whether the time is unset, so omitempty omits it in formats which
+// honor IsZero
+func (t JsonTime) IsZero() bool {
+	return t.Time == nil
+}
+
+func (t JsonTime) text() string {
+	return t.Time.UTC().Round(time.Second).Format(time.RFC3339)
+}
+
+func (t JsonTime) MarshalJSON() ([]byte, error) {
 	if t.Time == nil {
 		return []byte("null"), nil
 	}
 
-	return []byte(fmt.Sprintf("\"%s\"", t.Time.UTC().Round(time.Second).Format(time.RFC3339))), nil
+	return []byte(fmt.Sprintf("\"%s\"", t.text())), nil
+}
+
+func (t JsonTime) MarshalCBOR() ([]byte, error) {
+	if t.Time == nil {
+		return cbor.Marshal(nil)
+	}
+
+	return cbor.Marshal(t.text())
+}
+
+func (t JsonTime) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if t.Time == nil {
+		return enc.EncodeNil()
+	}
+
+	return enc.EncodeString(t.text())
 }
 
+// Encodes as hours, minutes, seconds and microseconds in every wire format
 type JsonDuration struct {
 	time.Duration
 }
 
-func (t *JsonDuration) MarshalJSON() ([]byte, error) {
-	return []byte(fmt.Sprintf(
-		"\"%02d:%02d:%02d.%06d\"",
+func (t JsonDuration) text() string {
+	return fmt.Sprintf(
+		"%02d:%02d:%02d.%06d",
 		int(t.Duration.Hours()),
 		int(t.Duration.Minutes()),
 		int(t.Duration.Seconds()),
-		t.Duration.Microseconds()),
-	), nil
+		t.Duration.Microseconds(),
+	)
+}
+
+func (t JsonDuration) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("\"%s\"", t.text())), nil
+}
+
+func (t JsonDuration) MarshalCBOR() ([]byte, error) {
+	return cbor.Marshal(t.text())
+}
+
+func (t JsonDuration) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return enc.EncodeString(t.text())
 }
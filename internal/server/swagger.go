@@ -1,19 +1,18 @@
 package server
 
 import (
-	"fmt"
-
 	"github.com/go-chi/chi/v5"
 	"github.com/swaggo/http-swagger/v2"
 )
 
 func (s *Server) swaggerRouter(r chi.Router) {
-	r.Get(
-		"/swagger/*",
-		httpSwagger.Handler(
-			httpSwagger.URL(
-				fmt.Sprintf("http://localhost:%d/swagger/doc.json", s.port),
-			),
-		),
-	)
+	cfg := s.config.Load().Security
+	r.Route("/swagger", func(r chi.Router) {
+		r.Use(s.useCors("docs"))
+		r.Use(s.useSecurityHeaders(cfg, cfg.DocsContentSecurityPolicy))
+
+		// Relative to the UI, so it is fetched from whichever host and
+		// scheme the UI was, as the Content-Security-Policy requires
+		r.Get("/*", httpSwagger.Handler(httpSwagger.URL("doc.json")))
+	})
 }
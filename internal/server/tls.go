@@ -0,0 +1,168 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"go-chi-api/internal/config"
+)
+
+var (
+	ErrClientCAFileRequired = errors.New("TLS_CLIENT_CA_FILE is required to verify client certificates")
+	ErrIncompleteKeyPair    = errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+)
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"verify_if_given":    tls.VerifyClientCertIfGiven,
+	"require_and_verify": tls.RequireAndVerifyClientCert,
+}
+
+// Returns the TLS configuration described by cfg, or nil if TLS is disabled.
+// Setting only one of the files is an error, rather than serving plain HTTP
+// where HTTPS was asked for
+func newTLSConfig(cfg config.TLS, logger *slog.Logger) (*tls.Config, error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		return nil, nil
+	}
+
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, ErrIncompleteKeyPair
+	}
+
+	reloader, err := newCertReloader(cfg.CertFile, cfg.KeyFile, cfg.ReloadInterval, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tlsVersions[cfg.MinVersion],
+		ClientAuth:     clientAuthTypes[cfg.ClientAuth],
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	if len(cfg.CipherSuites) > 0 {
+		// Go ignores the list for TLS 1.3, so its suites are rejected rather
+		// than seeming to be restricted
+		suites := make(map[string]uint16)
+		tls13 := make(map[string]bool)
+		for _, suite := range tls.CipherSuites() {
+			if slices.Contains(suite.SupportedVersions, tls.VersionTLS12) {
+				suites[suite.Name] = suite.ID
+			} else {
+				tls13[suite.Name] = true
+			}
+		}
+
+		for _, name := range cfg.CipherSuites {
+			id, ok := suites[name]
+			switch {
+			case tls13[name]:
+				return nil, fmt.Errorf("TLS_CIPHER_SUITES: %s is a TLS 1.3 cipher suite, which cannot be configured", name)
+			case !ok:
+				return nil, fmt.Errorf("TLS_CIPHER_SUITES: %s is not a supported secure cipher suite", name)
+			}
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+		}
+	}
+
+	if tlsConfig.ClientAuth >= tls.VerifyClientCertIfGiven {
+		if cfg.ClientCAFile == "" {
+			return nil, ErrClientCAFileRequired
+		}
+
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("TLS_CLIENT_CA_FILE: no certificates found in %s", cfg.ClientCAFile)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// Serves the certificate from a pair of files, loading it again when either
+// changes. The files are checked during handshakes, at most once an interval.
+// If they cannot be loaded, as when only one has been replaced so far, the
+// previous certificate is served until they can
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	logger   *slog.Logger
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	modTimes    [2]time.Time
+	lastChecked time.Time
+}
+
+func newCertReloader(certFile string, keyFile string, interval time.Duration, logger *slog.Logger) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval, logger: logger}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := time.Now(); now.Sub(r.lastChecked) >= r.interval {
+		r.lastChecked = now
+		if modTimes := r.modified(); modTimes != r.modTimes {
+			if err := r.load(); err != nil {
+				r.logger.Warn("Failed to reload TLS certificate, keeping current certificate", "error", err)
+			} else {
+				r.logger.Info("TLS certificate reloaded", "file", r.certFile)
+			}
+		}
+	}
+
+	return r.cert, nil
+}
+
+func (r *certReloader) load() error {
+	modTimes := r.modified()
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.cert = &cert
+	r.modTimes = modTimes
+	return nil
+}
+
+// Returns when the files were last modified, or zero times for any which
+// cannot be read
+func (r *certReloader) modified() [2]time.Time {
+	var modTimes [2]time.Time
+	for i, path := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(path); err == nil {
+			modTimes[i] = info.ModTime()
+		}
+	}
+
+	return modTimes
+}
@@ -0,0 +1,31 @@
+package validation
+
+import (
+	"regexp"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type customValidation struct {
+	tag      string
+	fn       validator.Func
+	messages map[string]string
+}
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]*$`)
+
+// Validations available to every request type, in addition to the validator's
+// built in validations
+var customValidations = []customValidation{
+	{
+		tag: "username",
+		fn: func(fl validator.FieldLevel) bool {
+			return usernamePattern.MatchString(fl.Field().String())
+		},
+		messages: map[string]string{
+			"en": "{0} may only contain letters, numbers, '.', '_' and '-'",
+			"es": "{0} solo puede contener letras, números, '.', '_' y '-'",
+			"fr": "{0} ne peut contenir que des lettres, des chiffres, '.', '_' et '-'",
+		},
+	},
+}
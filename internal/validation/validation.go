@@ -0,0 +1,228 @@
+// Package validation validates request bodies, describing each invalid field
+// in the language the client prefers. Fields are named by their JSON names,
+// as clients know them
+package validation
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go-chi-api/internal/problem"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+)
+
+type Validator struct {
+	validate   *validator.Validate
+	translator *ut.UniversalTranslator
+}
+
+// The locales messages are available in, with the default first
+var supported = []struct {
+	locale   locales.Translator
+	register func(*validator.Validate, ut.Translator) error
+
+	// Replace the default messages of the validator's translations
+	overrides map[string]string
+}{
+	{
+		locale:   en.New(),
+		register: en_translations.RegisterDefaultTranslations,
+		overrides: map[string]string{
+			"min-string": "{0} must be at least {1}",
+			"max-string": "{0} must be at most {1}",
+		},
+	},
+	{locale: es.New(), register: es_translations.RegisterDefaultTranslations},
+	{locale: fr.New(), register: fr_translations.RegisterDefaultTranslations},
+}
+
+// Creates a validator with translations for every supported locale, and the
+// custom validations shared by all request types
+func New() (*Validator, error) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(jsonName)
+
+	translators := make([]locales.Translator, len(supported))
+	for i, s := range supported {
+		translators[i] = s.locale
+	}
+	translator := ut.New(translators[0], translators...)
+
+	for _, s := range supported {
+		trans, _ := translator.GetTranslator(s.locale.Locale())
+		if err := s.register(validate, trans); err != nil {
+			return nil, err
+		}
+
+		for key, text := range s.overrides {
+			if err := trans.Add(key, text, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	v := &Validator{validate: validate, translator: translator}
+	for _, custom := range customValidations {
+		if err := v.RegisterValidation(custom.tag, custom.fn, custom.messages); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+// Registers a validation for use in validate tags, with its message in each
+// locale. The message's {0} is replaced by the field's name, and messages
+// missing for a locale fall back to the default locale's
+func (v *Validator) RegisterValidation(tag string, fn validator.Func, messages map[string]string) error {
+	if err := v.validate.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+
+	fallback := messages[supported[0].locale.Locale()]
+	for _, s := range supported {
+		message, ok := messages[s.locale.Locale()]
+		if !ok {
+			message = fallback
+		}
+
+		trans, _ := v.translator.GetTranslator(s.locale.Locale())
+		err := v.validate.RegisterTranslation(
+			tag,
+			trans,
+			func(trans ut.Translator) error {
+				return trans.Add(tag, message, true)
+			},
+			func(trans ut.Translator, fe validator.FieldError) string {
+				text, _ := trans.T(tag, fe.Field())
+				return text
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Validates the fields of a struct, returning validator.ValidationErrors if
+// any are invalid
+func (v *Validator) Struct(s any) error {
+	return v.validate.Struct(s)
+}
+
+// Returns the translator for the most preferred supported locale in an
+// Accept-Language header, or the default locale if none are supported
+func (v *Validator) Translator(acceptLanguage string) ut.Translator {
+	trans, _ := v.translator.FindTranslator(preferredLocales(acceptLanguage)...)
+	return trans
+}
+
+// Returns an error for each invalid field, described in the language of trans
+func (v *Validator) FieldErrors(errs validator.ValidationErrors, trans ut.Translator) []problem.FieldError {
+	fieldErrors := make([]problem.FieldError, len(errs))
+	for i, err := range errs {
+		fieldErrors[i] = problem.FieldError{
+			Pointer: Pointer(err.Namespace()),
+			Detail:  err.Translate(trans),
+		}
+	}
+
+	return fieldErrors
+}
+
+// Converts a validator namespace, such as "Request.items[0].name", to a JSON
+// pointer, such as "/items/0/name". The leading struct name is dropped
+func Pointer(namespace string) string {
+	_, path, _ := strings.Cut(namespace, ".")
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+
+	escape := strings.NewReplacer("~", "~0", "/", "~1")
+	var pointer strings.Builder
+	for _, token := range strings.Split(path, ".") {
+		pointer.WriteString("/")
+		pointer.WriteString(escape.Replace(token))
+	}
+
+	return pointer.String()
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	default:
+		return name
+	}
+}
+
+// Returns the locales of an Accept-Language header in order of preference, as
+// go-playground locale names. Each regional locale is followed by its
+// language, so "fr-CA" matches "fr" when "fr_CA" is not supported
+func preferredLocales(acceptLanguage string) []string {
+	type preference struct {
+		locale  string
+		quality float64
+	}
+
+	var preferences []preference
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		preferences = append(preferences, preference{strings.ReplaceAll(tag, "-", "_"), quality})
+	}
+
+	// Stable, so equally preferred locales keep the client's order
+	slices.SortStableFunc(preferences, func(a, b preference) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	var names []string
+	for _, p := range preferences {
+		language, region, regional := strings.Cut(p.locale, "_")
+		language = strings.ToLower(language)
+		if regional {
+			names = append(names, language+"_"+strings.ToUpper(region))
+		}
+		names = append(names, language)
+	}
+
+	return names
+}
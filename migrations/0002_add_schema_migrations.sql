@@ -0,0 +1,5 @@
+CREATE TABLE goapi.schema_migrations (
+    version text PRIMARY KEY,
+    applied_at timestamp with time zone NOT NULL DEFAULT now()
+);
+INSERT INTO goapi.schema_migrations (version) VALUES ('0000'), ('0001'), ('0002');
@@ -0,0 +1,10 @@
+CREATE TABLE goapi.idempotency_keys (
+    key text PRIMARY KEY,
+    fingerprint text NOT NULL,
+    status integer,
+    headers jsonb,
+    body bytea,
+    expires_at timestamp with time zone NOT NULL
+);
+CREATE INDEX idempotency_keys_expires_at_idx ON goapi.idempotency_keys (expires_at);
+INSERT INTO goapi.schema_migrations (version) VALUES ('0003');
@@ -0,0 +1,10 @@
+CREATE TABLE goapi.rate_limits (
+    key text PRIMARY KEY,
+    value double precision NOT NULL,
+    previous double precision NOT NULL,
+    time timestamp with time zone NOT NULL,
+    version bigint NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+CREATE INDEX rate_limits_expires_at_idx ON goapi.rate_limits (expires_at);
+INSERT INTO goapi.schema_migrations (version) VALUES ('0004');
@@ -0,0 +1,28 @@
+// Package migrations embeds the SQL migrations, which are applied in order of
+// their file names. Each migration from 0002 onwards records its version in
+// goapi.schema_migrations, so the service can tell which are pending
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+	"strings"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Returns the version of every migration, in the order they are applied
+func Versions() ([]string, error) {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]string, len(names))
+	for i, name := range names {
+		versions[i], _, _ = strings.Cut(name, "_")
+	}
+
+	return versions, nil
+}
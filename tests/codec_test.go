@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"bytes"
+	"go-chi-api/internal/codec"
+	"go-chi-api/internal/problem"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func doEncoded(t *testing.T, h http.Handler, method string, path string, c codec.Codec, body any, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		b, err := c.Marshal(body)
+		if err != nil {
+			t.Fatalf("error marshaling request. Err: %v", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req := httptest.NewRequest(method, path, nil)
+	if reader != nil {
+		req = httptest.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", c.MediaTypes()[0])
+	}
+	req.Header.Set("Accept", c.MediaTypes()[0])
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBinaryFormatsRoundTrip(t *testing.T) {
+	credentials := map[string]string{
+		"username": "myusername123",
+		"password": "superpassword1234",
+	}
+
+	for _, c := range []codec.Codec{codec.CBOR, codec.MessagePack} {
+		t.Run(c.MediaTypes()[0], func(t *testing.T) {
+			h := newTestServer(t).Handler()
+
+			if rec := doEncoded(t, h, http.MethodPost, "/v1.0/auth/register", c, credentials); rec.Code != http.StatusCreated {
+				t.Fatalf("expected register status 201; got %d: %s", rec.Code, rec.Body)
+			}
+			rec := doEncoded(t, h, http.MethodPost, "/v1.0/auth/login", c, credentials)
+			if rec.Code != http.StatusNoContent {
+				t.Fatalf("expected login status 204; got %d: %s", rec.Code, rec.Body)
+			}
+
+			rec = doEncoded(t, h, http.MethodGet, "/v1.0/auth/current/", c, nil, rec.Result().Cookies()...)
+			if contentType := rec.Header().Get("Content-Type"); contentType != c.MediaTypes()[0] {
+				t.Fatalf("expected Content-Type %s; got %s: %s", c.MediaTypes()[0], contentType, rec.Body)
+			}
+
+			// Times are encoded by their custom marshalers, as in JSON
+			var response map[string]any
+			if err := c.Decode(rec.Body, &response, codec.DecodeOptions{}); err != nil {
+				t.Fatalf("error decoding response. Err: %v", err)
+			}
+			if response["username"] != credentials["username"] || response["created_at"] != "2024-01-02T03:04:05Z" {
+				t.Errorf("expected current user with RFC 3339 created_at; got %v", response)
+			}
+			if response["updated_at"] != nil {
+				t.Errorf("expected no updated_at; got %v", response["updated_at"])
+			}
+
+			rec = doEncoded(t, h, http.MethodGet, "/v1.0/health/live", c, nil)
+			response = nil
+			if err := c.Decode(rec.Body, &response, codec.DecodeOptions{}); err != nil {
+				t.Fatalf("error decoding health response. Err: %v", err)
+			}
+			if response["duration"] != "00:00:00.000000" {
+				t.Errorf("expected duration formatted as in JSON; got %v", response["duration"])
+			}
+
+			rec = doEncoded(t, h, http.MethodPost, "/v1.0/auth/login", c, map[string]any{"username": "myusername123", "password": "superpassword1234", "extra": 1})
+			if p := decodeProblem(t, rec); p.Type != problem.MalformedBody.URI {
+				t.Errorf("expected malformed body problem for an unknown field; got %+v", p)
+			}
+		})
+	}
+}
+
+func TestContentNegotiation(t *testing.T) {
+	h := newTestServer(t).Handler()
+
+	cases := map[string]string{
+		"":                                      "application/json",
+		"*/*":                                   "application/json",
+		"application/json, application/*;q=0.5": "application/json",
+		"application/json;q=0.5, application/vnd.msgpack":   "application/msgpack",
+		"*/*;q=0.5, application/json;q=0, application/cbor": "application/cbor",
+	}
+	for accept, expected := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/v1.0/", nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if contentType := rec.Header().Get("Content-Type"); contentType != expected {
+			t.Errorf("expected %s for Accept %q; got %s", expected, accept, contentType)
+		}
+		if vary := rec.Header().Values("Vary"); !slices.Contains(vary, "Accept") {
+			t.Errorf("expected responses to vary by Accept; got %q", vary)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1.0/", nil)
+	req.Header.Set("Accept", "text/html, application/json;q=0")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if p := decodeProblem(t, rec); p.Type != problem.NotAcceptable.URI {
+		t.Errorf("expected not acceptable problem; got %+v", p)
+	}
+
+	body := `<user/>`
+	rec = doRaw(h, "/v1.0/auth/register", "application/xml", body, int64(len(body)))
+	if p := decodeProblem(t, rec); p.Type != problem.UnsupportedMediaType.URI {
+		t.Errorf("expected unsupported media type problem; got %+v", p)
+	}
+}
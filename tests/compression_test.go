@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"go-chi-api/internal/health"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func getWithEncoding(h http.Handler, path string, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func decompress(t *testing.T, encoding string, body io.Reader) string {
+	t.Helper()
+	var reader io.Reader
+	switch encoding {
+	case "gzip":
+		r, err := gzip.NewReader(body)
+		if err != nil {
+			t.Fatalf("error reading gzip body. Err: %v", err)
+		}
+		reader = r
+	case "br":
+		reader = brotli.NewReader(body)
+	case "zstd":
+		r, err := zstd.NewReader(body)
+		if err != nil {
+			t.Fatalf("error reading zstd body. Err: %v", err)
+		}
+		defer r.Close()
+		reader = r
+	}
+
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("error decompressing %s body. Err: %v", encoding, err)
+	}
+	return string(b)
+}
+
+func TestResponsesAreCompressed(t *testing.T) {
+	s := newTestServer(t)
+	for i := range 20 {
+		registerCheck(t, s, health.Check{
+			Name: fmt.Sprintf("Check %d", i),
+			Checker: health.CheckerFunc(func(context.Context) health.Result {
+				return health.Result{Status: health.Healthy, Description: strings.Repeat("healthy ", 10)}
+			}),
+		})
+	}
+	h := s.Handler()
+
+	identity := getWithEncoding(h, "/v1.0/health/", "")
+	if identity.Header().Get("Content-Encoding") != "" || identity.Body.Len() < 1024 {
+		t.Fatalf("expected a large uncompressed response without Accept-Encoding; got %d bytes encoded as %q", identity.Body.Len(), identity.Header().Get("Content-Encoding"))
+	}
+
+	cases := map[string]string{
+		"gzip":               "gzip",
+		"br":                 "br",
+		"zstd":               "zstd",
+		"gzip, br, zstd":     "zstd",
+		"gzip;q=1, br;q=0.5": "gzip",
+		"*, zstd;q=0":        "br",
+		"deflate, x-unknown": "",
+		"gzip;q=0, identity": "",
+	}
+	for acceptEncoding, expected := range cases {
+		rec := getWithEncoding(h, "/v1.0/health/", acceptEncoding)
+		if encoding := rec.Header().Get("Content-Encoding"); encoding != expected {
+			t.Errorf("expected encoding %q for Accept-Encoding %q; got %q", expected, acceptEncoding, encoding)
+			continue
+		}
+		if vary := rec.Header().Values("Vary"); !slices.Contains(vary, "Accept-Encoding") {
+			t.Errorf("expected responses to vary by Accept-Encoding; got %q", vary)
+		}
+		if expected == "" {
+			continue
+		}
+
+		// The handler's Content-Length is of the uncompressed body
+		if length := rec.Header().Get("Content-Length"); length != "" {
+			t.Errorf("expected no Content-Length for a %s body; got %s", expected, length)
+		}
+		if body := decompress(t, expected, rec.Body); body != identity.Body.String() {
+			t.Errorf("expected %s body to decompress to the uncompressed body; got %s", expected, body)
+		}
+	}
+}
+
+func TestSmallResponsesAreNotCompressed(t *testing.T) {
+	h := newTestServer(t).Handler()
+
+	rec := getWithEncoding(h, "/v1.0/", "gzip")
+	if encoding := rec.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("expected a small response to be sent uncompressed; got %q", encoding)
+	}
+	if length := rec.Header().Get("Content-Length"); length != fmt.Sprint(rec.Body.Len()) {
+		t.Errorf("expected Content-Length %d; got %s", rec.Body.Len(), length)
+	}
+	if vary := rec.Header().Values("Vary"); !slices.Contains(vary, "Accept-Encoding") {
+		t.Errorf("expected responses to vary by Accept-Encoding; got %q", vary)
+	}
+}
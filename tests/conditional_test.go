@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"go-chi-api/internal/conditional"
+	"go-chi-api/internal/problem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Registers and logs in a user, returning the authentication cookie
+func login(t *testing.T, h http.Handler) *http.Cookie {
+	t.Helper()
+	credentials := map[string]string{"username": "myusername123", "password": "superpassword1234"}
+	if rec := doJson(t, h, http.MethodPost, "/v1.0/auth/register", credentials); rec.Code != http.StatusCreated {
+		t.Fatalf("expected register status 201; got %d", rec.Code)
+	}
+
+	rec := doJson(t, h, http.MethodPost, "/v1.0/auth/login", credentials)
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusNoContent || len(cookies) != 1 {
+		t.Fatalf("expected login to set a cookie; got status %d", rec.Code)
+	}
+	return cookies[0]
+}
+
+func getConditionally(h http.Handler, cookie *http.Cookie, header string, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/v1.0/auth/current/", nil)
+	req.AddCookie(cookie)
+	req.Header.Set(header, value)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCurrentUserSupportsConditionalRequests(t *testing.T) {
+	h := newTestServer(t).Handler()
+	cookie := login(t, h)
+
+	rec := doJson(t, h, http.MethodGet, "/v1.0/auth/current/", nil, cookie)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected current user with an ETag; got status %d and ETag %q", rec.Code, etag)
+	}
+	if lastModified := rec.Header().Get("Last-Modified"); lastModified != "Tue, 02 Jan 2024 03:04:05 GMT" {
+		t.Errorf("expected Last-Modified of the user's creation; got %q", lastModified)
+	}
+
+	cases := []struct {
+		header string
+		value  string
+		status int
+	}{
+		{"If-None-Match", etag, http.StatusNotModified},
+		{"If-None-Match", conditional.Weak(etag), http.StatusNotModified},
+		{"If-None-Match", `"other", ` + etag, http.StatusNotModified},
+		{"If-None-Match", `"other"`, http.StatusOK},
+		{"If-Modified-Since", "Tue, 02 Jan 2024 03:04:05 GMT", http.StatusNotModified},
+		{"If-Modified-Since", "Tue, 02 Jan 2024 03:04:04 GMT", http.StatusOK},
+		{"If-Match", etag, http.StatusOK},
+		{"If-Match", `"other"`, http.StatusPreconditionFailed},
+		{"If-Unmodified-Since", "Mon, 01 Jan 2024 00:00:00 GMT", http.StatusPreconditionFailed},
+	}
+	for _, c := range cases {
+		rec := getConditionally(h, cookie, c.header, c.value)
+		if rec.Code != c.status {
+			t.Errorf("expected status %d for %s: %s; got %d", c.status, c.header, c.value, rec.Code)
+			continue
+		}
+
+		switch c.status {
+		case http.StatusNotModified:
+			if rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+				t.Errorf("expected 304 with the ETag and no body for %s: %s", c.header, c.value)
+			}
+		case http.StatusPreconditionFailed:
+			if p := decodeProblem(t, rec); p.Type != problem.PreconditionFailed.URI {
+				t.Errorf("expected precondition failed problem; got %+v", p)
+			}
+		}
+	}
+}
+
+func TestPreconditionsOfMutatingRequests(t *testing.T) {
+	etag := conditional.ETag([]byte(`{"id":1}`))
+	lastModified := time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	cases := []struct {
+		name         string
+		header       string
+		value        string
+		etag         string
+		lastModified time.Time
+		expected     conditional.Result
+	}{
+		{"matching If-Match", "If-Match", etag, etag, lastModified, conditional.Proceed},
+		{"stale If-Match", "If-Match", `"stale"`, etag, lastModified, conditional.PreconditionFailed},
+		{"weak If-Match", "If-Match", conditional.Weak(etag), etag, lastModified, conditional.PreconditionFailed},
+		{"If-Match any", "If-Match", "*", etag, lastModified, conditional.Proceed},
+		{"If-Match any without a resource", "If-Match", "*", "", time.Time{}, conditional.PreconditionFailed},
+		{"If-None-Match any", "If-None-Match", "*", etag, lastModified, conditional.PreconditionFailed},
+		{"If-None-Match any without a resource", "If-None-Match", "*", "", time.Time{}, conditional.Proceed},
+		{"unmodified", "If-Unmodified-Since", "Tue, 02 Jan 2024 03:04:05 GMT", etag, lastModified, conditional.Proceed},
+		{"modified", "If-Unmodified-Since", "Tue, 02 Jan 2024 03:04:04 GMT", etag, lastModified, conditional.PreconditionFailed},
+		{"invalid date", "If-Unmodified-Since", "yesterday", etag, lastModified, conditional.Proceed},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPut, "/v1.0/resource", nil)
+		req.Header.Set(c.header, c.value)
+		if result := conditional.Check(req, c.etag, c.lastModified); result != c.expected {
+			t.Errorf("expected result %d for %s; got %d", c.expected, c.name, result)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"go-chi-api/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setRequiredConfigEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("PORT", "3000")
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_DATABASE", "goapi")
+	t.Setenv("DB_USERNAME", "goapi")
+	t.Setenv("JWT_SECRET", "test-secret-that-is-long-enough")
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	setRequiredConfigEnv(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	contents := "server:\n  read_timeout: 7s\n  write_timeout: 8s\n  idle_timeout: 9s\n"
+	if err := os.WriteFile(file, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("SERVER_WRITE_TIMEOUT", "10s")
+
+	cfg, err := config.Load([]string{"-server-idle-timeout", "11s"})
+	if err != nil {
+		t.Fatalf("error loading config. Err: %v", err)
+	}
+
+	if cfg.Server.ReadTimeout != 7*time.Second {
+		t.Errorf("expected file to override default; got %s", cfg.Server.ReadTimeout)
+	}
+	if cfg.Server.WriteTimeout != 10*time.Second {
+		t.Errorf("expected env to override file; got %s", cfg.Server.WriteTimeout)
+	}
+	if cfg.Server.IdleTimeout != 11*time.Second {
+		t.Errorf("expected flag to override env; got %s", cfg.Server.IdleTimeout)
+	}
+	if cfg.Server.ShutdownTimeout != time.Minute {
+		t.Errorf("expected default shutdown timeout; got %s", cfg.Server.ShutdownTimeout)
+	}
+}
+
+func TestConfigReadsSecretFiles(t *testing.T) {
+	setRequiredConfigEnv(t)
+	file := filepath.Join(t.TempDir(), "db_password")
+	if err := os.WriteFile(file, []byte("from-a-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DB_PASSWORD_FILE", file)
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("error loading config. Err: %v", err)
+	}
+	if cfg.Database.Password != "from-a-file" {
+		t.Errorf("expected password from file; got %q", cfg.Database.Password)
+	}
+
+	var out bytes.Buffer
+	if err := cfg.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "from-a-file") || strings.Contains(out.String(), "test-secret") {
+		t.Errorf("expected secrets to be redacted; got\n%s", out.String())
+	}
+}
+
+func TestConfigAggregatesErrors(t *testing.T) {
+	setRequiredConfigEnv(t)
+	t.Setenv("PORT", "70000")
+	t.Setenv("DB_HOST", "")
+	t.Setenv("SERVER_READ_TIMEOUT", "soon")
+	t.Setenv("SERVER_DRAIN_DELAY", "-1s")
+	t.Setenv("COMPRESSION_ENCODINGS", "gzip,deflate")
+	t.Setenv("CORS_API_ALLOWED_ORIGINS", "*")
+
+	// A client certificate is useless without its key
+	clientCertificate := filepath.Join(t.TempDir(), "client.pem")
+	if err := os.WriteFile(clientCertificate, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE", clientCertificate)
+
+	_, err := config.Load(nil)
+	if !errors.Is(err, config.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig; got %v", err)
+	}
+
+	for _, expected := range []string{
+		"SERVER_READ_TIMEOUT",
+		"$PORT",
+		"$DB_HOST",
+		"server.drain_delay ($SERVER_DRAIN_DELAY) must be at least 0",
+		"compression.encodings[1] ($COMPRESSION_ENCODINGS) must be one of",
+		"$OTEL_EXPORTER_OTLP_CLIENT_KEY",
+		"cors.api.allowed_origins ($CORS_API_ALLOWED_ORIGINS) cannot include * when AllowCredentials is set",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error to mention %s; got %v", expected, err)
+		}
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	setRequiredConfigEnv(t)
+	file := filepath.Join(t.TempDir(), "config.toml")
+	write := func(contents string) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("[runtime]\nlog_level = \"info\"\n")
+	args := []string{"-config", file}
+	cfg, err := config.Load(args)
+	if err != nil {
+		t.Fatalf("error loading config. Err: %v", err)
+	}
+
+	store := config.NewStore(cfg, args)
+	var reloads []error
+	store.OnReload(func(_ *config.Config, err error) {
+		reloads = append(reloads, err)
+	})
+
+	write("[runtime]\nlog_level = \"verbose\"\n")
+	if err := store.Reload(); !errors.Is(err, config.ErrInvalidConfig) {
+		t.Fatalf("expected invalid reload to be rejected; got %v", err)
+	}
+	if level := store.Load().Runtime.LogLevel; level != "info" {
+		t.Errorf("expected rejected reload to keep log level info; got %s", level)
+	}
+
+	write("[server]\nshutdown_timeout = \"5s\"\n[runtime]\nlog_level = \"debug\"\nfeatures = [\"beta\"]\n")
+	if err := store.Reload(); err != nil {
+		t.Fatalf("expected reload to succeed; got %v", err)
+	}
+
+	current := store.Load()
+	if current.Runtime.LogLevel != "debug" || !current.Runtime.FeatureEnabled("beta") {
+		t.Errorf("expected runtime settings to be reloaded; got %+v", current.Runtime)
+	}
+	if current.Server.ShutdownTimeout != time.Minute {
+		t.Errorf("expected static settings to be unchanged; got %s", current.Server.ShutdownTimeout)
+	}
+	if cfg.Runtime.LogLevel != "info" {
+		t.Errorf("expected original snapshot to be unchanged; got %s", cfg.Runtime.LogLevel)
+	}
+	if len(reloads) != 2 || reloads[0] == nil || reloads[1] != nil {
+		t.Errorf("expected hooks to observe both reloads; got %v", reloads)
+	}
+}
+
+func TestReloadAppliesRateLimitsAndCors(t *testing.T) {
+	setRequiredConfigEnv(t)
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(contents string) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("rate_limit:\n  auth:\n    requests: 1\n")
+	args := []string{"-config", file}
+	cfg, err := config.Load(args)
+	if err != nil {
+		t.Fatalf("error loading config. Err: %v", err)
+	}
+
+	store := config.NewStore(cfg, args)
+	h := newTestServerWithStore(t, store).Handler()
+	login := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1.0/auth/login", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	login("")
+	if rec := login(""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the second request to be limited; got status %d", rec.Code)
+	}
+
+	write("rate_limit:\n  auth:\n    requests: 5\ncors:\n  api:\n    allowed_origins: [https://app.example.com]\n")
+	if err := store.Reload(); err != nil {
+		t.Fatalf("expected reload to succeed; got %v", err)
+	}
+
+	rec := login("https://app.example.com")
+	if rec.Code == http.StatusTooManyRequests || rec.Header().Get("RateLimit-Limit") != "5" {
+		t.Errorf("expected the reloaded limit to apply; got status %d and limit %s", rec.Code, rec.Header().Get("RateLimit-Limit"))
+	}
+	if origin := rec.Header().Get("Access-Control-Allow-Origin"); origin != "https://app.example.com" {
+		t.Errorf("expected the reloaded origins to be allowed; got %q", origin)
+	}
+}
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"go-chi-api/internal/config"
+	"go-chi-api/internal/server"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCorsAllowsConfiguredOrigins(t *testing.T) {
+	cfg := testConfig()
+	cfg.Cors.Api.AllowedOrigins = []string{"https://app.example.com", "https://*.example.org"}
+	h := newTestServerWithConfig(t, cfg).Handler()
+
+	cases := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://APP.example.com", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://evil.com/.example.org", false},
+		{"http://app.example.com", false},
+		{"https://app.example.com.evil.com", false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodOptions, "/v1.0/auth/login", nil)
+		req.Header.Set("Origin", c.origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "content-type")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		allowOrigin := rec.Header().Get("Access-Control-Allow-Origin")
+		if !c.allowed {
+			if allowOrigin != "" {
+				t.Errorf("expected %s to be disallowed; got Access-Control-Allow-Origin %q", c.origin, allowOrigin)
+			}
+			continue
+		}
+
+		if rec.Code != http.StatusNoContent || allowOrigin != c.origin {
+			t.Errorf("expected preflight from %s to be allowed; got status %d and origin %q", c.origin, rec.Code, allowOrigin)
+		}
+		if rec.Header().Get("Access-Control-Allow-Credentials") != "true" || rec.Header().Get("Access-Control-Max-Age") != "600" {
+			t.Errorf("expected preflight to allow credentials and be cached; got %v", rec.Header())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1.0/health/live", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" || rec.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Errorf("expected response to be readable by the origin; got %v", rec.Header())
+	}
+}
+
+func TestCorsIsAllowedPerRouteGroup(t *testing.T) {
+	cfg := testConfig()
+	cfg.Cors.Api.AllowedOrigins = []string{"https://app.example.com"}
+	cfg.Cors.Docs.AllowedOrigins = []string{"https://docs.example.com"}
+	h := newTestServerWithConfig(t, cfg).Handler()
+
+	allowedOrigin := func(path string, origin string) string {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	if origin := allowedOrigin("/swagger/doc.json", "https://docs.example.com"); origin != "https://docs.example.com" {
+		t.Errorf("expected the docs to allow their own origin; got %q", origin)
+	}
+	if origin := allowedOrigin("/swagger/doc.json", "https://app.example.com"); origin != "" {
+		t.Errorf("expected the docs not to allow the API's origin; got %q", origin)
+	}
+	if origin := allowedOrigin("/v1.0/health/live", "https://docs.example.com"); origin != "" {
+		t.Errorf("expected the API not to allow the docs' origin; got %q", origin)
+	}
+}
+
+func TestCorsRejectsCredentialsFromAnyOrigin(t *testing.T) {
+	cfg := testConfig()
+	cfg.Cors.Api.AllowedOrigins = []string{"*"}
+	_, err := server.NewServer(context.Background(), config.NewStore(cfg, nil), "test", server.WithDatabase(&fakeDatabase{}), server.WithOtel(fakeOtel{}))
+	if !errors.Is(err, server.ErrCorsCredentialsFromAnyOrigin) {
+		t.Fatalf("expected ErrCorsCredentialsFromAnyOrigin; got %v", err)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	h := newTestServer(t).Handler()
+	get := func(path string, forwardedProto string, remoteAddr ...string) http.Header {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if forwardedProto != "" {
+			req.Header.Set("X-Forwarded-Proto", forwardedProto)
+		}
+		if len(remoteAddr) > 0 {
+			req.RemoteAddr = remoteAddr[0]
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Header()
+	}
+
+	api := get("/v1.0/health/live", "")
+	if api.Get("X-Content-Type-Options") != "nosniff" || api.Get("X-Frame-Options") != "DENY" || api.Get("Referrer-Policy") != "no-referrer" {
+		t.Errorf("expected hardening headers; got %v", api)
+	}
+	if api.Get("Content-Security-Policy") != "default-src 'none'; frame-ancestors 'none'" {
+		t.Errorf("expected API Content-Security-Policy; got %q", api.Get("Content-Security-Policy"))
+	}
+	if api.Get("Strict-Transport-Security") != "" {
+		t.Errorf("expected no Strict-Transport-Security over HTTP")
+	}
+
+	if hsts := get("/v1.0/health/live", "https", "127.0.0.1:1234").Get("Strict-Transport-Security"); hsts != "max-age=31536000" {
+		t.Errorf("expected Strict-Transport-Security over HTTPS through a trusted proxy; got %q", hsts)
+	}
+	if hsts := get("/v1.0/health/live", "https").Get("Strict-Transport-Security"); hsts != "" {
+		t.Errorf("expected X-Forwarded-Proto from an untrusted client to be ignored; got %q", hsts)
+	}
+
+	docs := get("/swagger/index.html", "")
+	if csp := docs.Get("Content-Security-Policy"); csp == api.Get("Content-Security-Policy") || csp == "" {
+		t.Errorf("expected the Swagger UI's own Content-Security-Policy; got %q", csp)
+	}
+}
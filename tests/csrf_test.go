@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"encoding/json"
+	"go-chi-api/internal/problem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Logs in again, which changes state, so is protected from CSRF
+func relogin(h http.Handler, headers map[string]string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	body := `{"username":"myusername123","password":"superpassword1234"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1.0/auth/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCsrfTokenIsRequiredWithAuthenticationCookie(t *testing.T) {
+	h := newTestServer(t).Handler()
+	session := login(t, h)
+
+	rec := relogin(h, nil, session)
+	if p := decodeProblem(t, rec); p.Type != problem.CsrfTokenInvalid.URI {
+		t.Fatalf("expected CSRF token problem without a token; got %+v", p)
+	}
+
+	rec = doJson(t, h, http.MethodGet, "/v1.0/auth/csrf", nil, session)
+	var response struct{ Token string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected a CSRF token; got status %d", rec.Code)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != response.Token || !cookies[0].HttpOnly {
+		t.Fatalf("expected the token to be set as a cookie; got %v", cookies)
+	}
+	csrf := cookies[0]
+
+	if rec := relogin(h, map[string]string{"X-CSRF-Token": response.Token}, session, csrf); rec.Code != http.StatusNoContent {
+		t.Errorf("expected request with the token to succeed; got status %d", rec.Code)
+	}
+
+	// A token issued without the session cannot be used with it
+	rec = doJson(t, h, http.MethodGet, "/v1.0/auth/csrf", nil)
+	anonymous := rec.Result().Cookies()[0]
+	rec = relogin(h, map[string]string{"X-CSRF-Token": anonymous.Value}, session, anonymous)
+	if p := decodeProblem(t, rec); p.Type != problem.CsrfTokenInvalid.URI {
+		t.Errorf("expected CSRF token problem for another session's token; got %+v", p)
+	}
+
+	// Nothing verifies these, so they cannot excuse a cookie from the token
+	for _, header := range []string{"Authorization", "X-API-Key"} {
+		rec := relogin(h, map[string]string{header: "Bearer token"}, session)
+		if p := decodeProblem(t, rec); p.Type != problem.CsrfTokenInvalid.URI {
+			t.Errorf("expected CSRF token problem for a cookie sent with %s; got %+v", header, p)
+		}
+	}
+}
+
+func TestCsrfVerifiesOrigin(t *testing.T) {
+	cfg := testConfig()
+	cfg.Cors.Api.AllowedOrigins = []string{"https://app.example.com"}
+	h := newTestServerWithConfig(t, cfg).Handler()
+	session := login(t, h)
+
+	rec := doJson(t, h, http.MethodGet, "/v1.0/auth/csrf", nil, session)
+	csrf := rec.Result().Cookies()[0]
+
+	cases := []struct {
+		header  string
+		value   string
+		allowed bool
+	}{
+		{"Origin", "https://app.example.com", true},
+		{"Origin", "http://example.com", true},
+		{"Origin", "https://example.com", false},
+		{"Origin", "https://evil.com", false},
+		{"Origin", "null", false},
+		{"Referer", "https://app.example.com/login", true},
+		{"Referer", "https://evil.com/form", false},
+	}
+	for _, c := range cases {
+		rec := relogin(h, map[string]string{c.header: c.value, "X-CSRF-Token": csrf.Value}, session, csrf)
+		if !c.allowed {
+			if p := decodeProblem(t, rec); p.Type != problem.OriginNotAllowed.URI {
+				t.Errorf("expected origin problem for %s: %s; got %+v", c.header, c.value, p)
+			}
+			continue
+		}
+
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("expected %s: %s to be allowed; got status %d", c.header, c.value, rec.Code)
+		}
+	}
+}
+
+func TestCsrfAllowsCrossOriginRequestsWithoutCookie(t *testing.T) {
+	h := newTestServer(t).Handler()
+	login(t, h)
+
+	rec := relogin(h, map[string]string{"Origin": "https://evil.com", "Authorization": "Bearer token"})
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected cross-origin request without the cookie to be allowed; got status %d", rec.Code)
+	}
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"go-chi-api/internal/config"
+	"go-chi-api/internal/dashboards"
+	"go-chi-api/internal/database"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/otlptranslator"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var (
+	// Parts of a PromQL expression which never name a metric: label matchers,
+	// ranges, grouping clauses and strings
+	promqlNonMetrics = regexp.MustCompile(`\{[^}]*\}|\[[^\]]*\]|\b(?:by|without|on|ignoring|group_left|group_right)\s*\([^)]*\)|"[^"]*"`)
+	promqlIdentifier = regexp.MustCompile(`[a-zA-Z_:][a-zA-Z0-9_:]*(\s*\()?`)
+	promqlKeywords   = []string{"and", "or", "unless", "bool", "offset"}
+)
+
+// Returns the names of the metrics selected by a PromQL expression
+func promqlMetrics(expr string) []string {
+	var metrics []string
+	for _, match := range promqlIdentifier.FindAllStringSubmatch(promqlNonMetrics.ReplaceAllString(expr, " "), -1) {
+		isFunction := match[1] != ""
+		if !isFunction && !slices.Contains(promqlKeywords, match[0]) {
+			metrics = append(metrics, match[0])
+		}
+	}
+
+	return metrics
+}
+
+// Returns the Prometheus names of every series the metrics collected by reader
+// are exported as
+func emittedSeries(t *testing.T) map[string]bool {
+	t.Helper()
+	_, reader := installTestTelemetry()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	series := make(map[string]bool)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metric := dashboards.Metric{Name: m.Name, Unit: m.Unit}
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				metric.Type = otlptranslator.MetricTypeNonMonotonicCounter
+				if data.IsMonotonic {
+					metric.Type = otlptranslator.MetricTypeMonotonicCounter
+				}
+			case metricdata.Sum[float64]:
+				metric.Type = otlptranslator.MetricTypeNonMonotonicCounter
+				if data.IsMonotonic {
+					metric.Type = otlptranslator.MetricTypeMonotonicCounter
+				}
+			case metricdata.Histogram[int64], metricdata.Histogram[float64]:
+				metric.Type = otlptranslator.MetricTypeHistogram
+			default:
+				metric.Type = otlptranslator.MetricTypeGauge
+			}
+
+			name := dashboards.PrometheusName(metric)
+			series[name] = true
+			if metric.Type == otlptranslator.MetricTypeHistogram {
+				series[metric.Bucket()] = true
+				series[metric.Count()] = true
+				series[metric.Sum()] = true
+			}
+		}
+	}
+
+	return series
+}
+
+func TestDashboardsQueryEmittedMetrics(t *testing.T) {
+	installTestTelemetry()
+
+	// Exercise the code emitting each metric, so that it has been recorded
+	h := newTestServer(t).Handler()
+	credentials := map[string]string{"username": "dashboards", "password": "correct horse battery"}
+	doJson(t, h, http.MethodPost, "/v1.0/auth/register", credentials)
+	doJson(t, h, http.MethodPost, "/v1.0/auth/login", credentials)
+	doJson(t, h, http.MethodGet, "/v1.0/auth/current/", nil, &http.Cookie{Name: "token", Value: "not-a-jwt"})
+
+	cfg := config.Default()
+	cfg.Database.Host = "localhost"
+	cfg.Database.Name = "dashboards"
+	if _, err := database.New(cfg.Database); err != nil {
+		t.Fatal(err)
+	}
+
+	known := emittedSeries(t)
+	var exprs []string
+	for _, group := range dashboards.RuleGroups() {
+		for _, rule := range group.Rules {
+			if rule.Record != "" {
+				known[rule.Record] = true
+			}
+			exprs = append(exprs, rule.Expr)
+		}
+	}
+	for _, dashboard := range dashboards.Dashboards() {
+		exprs = append(exprs, dashboard.Exprs()...)
+	}
+
+	for _, expr := range exprs {
+		metrics := promqlMetrics(expr)
+		if len(metrics) == 0 {
+			t.Errorf("no metrics found in %q", expr)
+		}
+
+		for _, metric := range metrics {
+			if !known[metric] {
+				t.Errorf("%s, queried by %q, is not emitted", metric, expr)
+			}
+		}
+	}
+}
+
+func TestGeneratedDashboardsAreUpToDate(t *testing.T) {
+	files, err := dashboards.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join("..", "o11y", filepath.FromSlash(name)))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("o11y/%s is out of date, run make o11y", name)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"go-chi-api/internal/problem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func doRaw(h http.Handler, path string, contentType string, body string, contentLength int64) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = contentLength
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDecodeAcceptsJsonMediaTypeParameters(t *testing.T) {
+	h := newTestServer(t).Handler()
+	body := `{"username": "myusername123", "password": "superpassword1234"}`
+
+	rec := doRaw(h, "/v1.0/auth/register", "application/json; charset=UTF-8", body, int64(len(body)))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201; got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = doRaw(h, "/v1.0/auth/register", "application/json; charset=latin1", body, int64(len(body)))
+	if p := decodeProblem(t, rec); p.Type != problem.UnsupportedMediaType.URI {
+		t.Errorf("expected unsupported media type problem; got %+v", p)
+	}
+}
+
+func TestDecodeRejectsOversizedBodies(t *testing.T) {
+	h := newTestServer(t).Handler()
+	body := `{"username": "myusername123", "password": "` + strings.Repeat("a", 8<<10) + `"}`
+
+	// Rejected up front when the length is declared, and once the limit is
+	// read otherwise
+	for _, contentLength := range []int64{int64(len(body)), -1} {
+		rec := doRaw(h, "/v1.0/auth/login", "application/json", body, contentLength)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("expected status 413 with Content-Length %d; got %d", contentLength, rec.Code)
+		}
+		if p := decodeProblem(t, rec); p.Type != problem.ContentTooLarge.URI {
+			t.Errorf("expected content too large problem; got %+v", p)
+		}
+	}
+}
+
+func TestDecodeReportsErrorLocations(t *testing.T) {
+	h := newTestServer(t).Handler()
+
+	cases := map[string]struct {
+		body    string
+		detail  string
+		pointer string
+	}{
+		"syntax": {
+			body:   "{\n  \"username\": ,\n}",
+			detail: "at line 2, column 15",
+		},
+		"type": {
+			body:    "{\n  \"username\": \"myusername123\",\n  \"password\": 1234\n}",
+			detail:  "ending at line 3, column 18",
+			pointer: "/password",
+		},
+		"unknown field": {
+			body:    `{"username": "myusername123", "password": "superpassword1234", "admin": true}`,
+			detail:  "unknown field",
+			pointer: "/admin",
+		},
+		"trailing data": {
+			body:   `{"username": "myusername123", "password": "superpassword1234"} {}`,
+			detail: "unexpected data after the JSON value at line 1, column 63",
+		},
+		"not an object": {
+			body:   `["myusername123"]`,
+			detail: "must be a JSON object",
+		},
+	}
+
+	for name, c := range cases {
+		rec := doRaw(h, "/v1.0/auth/login", "application/json", c.body, int64(len(c.body)))
+		p := decodeProblem(t, rec)
+		if p.Type != problem.MalformedBody.URI || !strings.Contains(p.Detail, c.detail) {
+			t.Errorf("expected %s problem detail containing %q; got %+v", name, c.detail, p)
+		}
+		if c.pointer != "" && (len(p.Errors) != 1 || p.Errors[0].Pointer != c.pointer) {
+			t.Errorf("expected %s problem pointing to %s; got %+v", name, c.pointer, p.Errors)
+		}
+	}
+}
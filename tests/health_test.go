@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"go-chi-api/internal/config"
+	"go-chi-api/internal/database"
+	"go-chi-api/internal/health"
+	"go-chi-api/internal/server"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func registerCheck(t *testing.T, s *server.Server, check health.Check) {
+	t.Helper()
+	if err := s.Health().Register(check); err != nil {
+		t.Fatalf("error registering health check. Err: %v", err)
+	}
+}
+
+func status(status health.Status) health.Checker {
+	return health.CheckerFunc(func(context.Context) health.Result {
+		return health.Result{Status: status, Description: string(status)}
+	})
+}
+
+type checkStatus struct {
+	Status      string
+	Required    bool
+	Description string
+}
+
+func TestHealthReportsOptionalFailuresAsDegraded(t *testing.T) {
+	s := newTestServer(t)
+	registerCheck(t, s, health.Check{Name: "Cache", Checker: status(health.Unhealthy)})
+	registerCheck(t, s, health.Check{
+		Name: "Slow",
+		Checker: health.CheckerFunc(func(ctx context.Context) health.Result {
+			<-ctx.Done()
+			return health.Result{Status: health.Healthy}
+		}),
+		Timeout: 10 * time.Millisecond,
+	})
+
+	rec := doJson(t, s.Handler(), http.MethodGet, "/v1.0/health/", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200; got %d", rec.Code)
+	}
+
+	var response struct {
+		Status string
+		Info   []struct {
+			Key         string
+			Status      string
+			Required    bool
+			Description string
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("error decoding response. Err: %v", err)
+	}
+	if response.Status != string(health.Degraded) {
+		t.Errorf("expected Degraded; got %s", response.Status)
+	}
+
+	statuses := make(map[string]checkStatus)
+	for _, info := range response.Info {
+		statuses[info.Key] = checkStatus{info.Status, info.Required, info.Description}
+	}
+	expected := map[string]checkStatus{
+		"Database": {"Healthy", true, "Healthy"},
+		"Cache":    {"Unhealthy", false, "Cache unavailable"},
+		"Slow":     {"Unhealthy", false, "Slow unavailable"},
+	}
+	for key, want := range expected {
+		if got := statuses[key]; got != want {
+			t.Errorf("expected %s check %+v; got %+v", key, want, got)
+		}
+	}
+}
+
+func TestHealthProbesFailOnRequiredFailures(t *testing.T) {
+	s := newTestServer(t)
+	registerCheck(t, s, health.Check{Name: "Queue", Checker: status(health.Unhealthy), Required: true})
+
+	expected := map[string]int{
+		"/v1.0/health/":        http.StatusServiceUnavailable,
+		"/v1.0/health/live":    http.StatusOK,
+		"/v1.0/health/ready":   http.StatusServiceUnavailable,
+		"/v1.0/health/startup": http.StatusServiceUnavailable,
+	}
+	for path, code := range expected {
+		if rec := doJson(t, s.Handler(), http.MethodGet, path, nil); rec.Code != code {
+			t.Errorf("expected %s status %d; got %d", path, code, rec.Code)
+		}
+	}
+}
+
+func TestReadinessFailsDuringShutdown(t *testing.T) {
+	s := newTestServer(t)
+	h := s.Handler()
+
+	for _, path := range []string{"/v1.0/health/startup", "/v1.0/health/ready"} {
+		if rec := doJson(t, h, http.MethodGet, path, nil); rec.Code != http.StatusOK {
+			t.Fatalf("expected %s status 200 before shutdown; got %d", path, rec.Code)
+		}
+	}
+
+	if err := s.Shutdown(); err != nil {
+		t.Fatalf("error shutting down. Err: %v", err)
+	}
+
+	expected := map[string]int{
+		"/v1.0/health/live":    http.StatusOK,
+		"/v1.0/health/ready":   http.StatusServiceUnavailable,
+		"/v1.0/health/startup": http.StatusOK,
+	}
+	for path, code := range expected {
+		if rec := doJson(t, h, http.MethodGet, path, nil); rec.Code != code {
+			t.Errorf("expected %s status %d after shutdown; got %d", path, code, rec.Code)
+		}
+	}
+}
+
+func TestHealthReportsDatabaseDetails(t *testing.T) {
+	db := &fakeDatabase{health: &health.Result{
+		Status:      health.Degraded,
+		Description: "Replication lag of 1m0s exceeds 30s",
+		Data:        map[string]any{"role": "replica", "replication_lag": "1m0s"},
+	}}
+	s := newTestServer(t, server.WithDatabase(db))
+
+	rec := doJson(t, s.Handler(), http.MethodGet, "/v1.0/health/", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200; got %d", rec.Code)
+	}
+
+	var response struct {
+		Status string
+		Info   []struct {
+			Key         string
+			Description string
+			Data        map[string]any
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("error decoding response. Err: %v", err)
+	}
+	if response.Status != string(health.Degraded) {
+		t.Errorf("expected Degraded; got %s", response.Status)
+	}
+	if len(response.Info) != 1 || response.Info[0].Data != nil || response.Info[0].Description != "Database degraded" {
+		t.Errorf("expected database details to be withheld from the public response; got %+v", response.Info)
+	}
+
+	rec = doJson(t, s.AdminHandler(), http.MethodGet, "/health", nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("error decoding response. Err: %v", err)
+	}
+	if len(response.Info) != 1 || response.Info[0].Data["role"] != "replica" ||
+		response.Info[0].Description != "Replication lag of 1m0s exceeds 30s" {
+		t.Errorf("expected database details from the admin listener; got %+v", response.Info)
+	}
+
+	if rec := doJson(t, s.Handler(), http.MethodGet, "/v1.0/health/ready", nil); rec.Code != http.StatusOK {
+		t.Errorf("expected degraded database to remain ready; got %d", rec.Code)
+	}
+}
+
+func TestDatabaseHealthFailsWhenUnreachable(t *testing.T) {
+	cfg := config.Default().Database
+	cfg.Host = "127.0.0.1"
+	cfg.Port = 1
+	cfg.Name = "unreachable"
+	cfg.Username = "test"
+
+	db, err := database.New(cfg)
+	if err != nil {
+		t.Fatalf("error creating database service. Err: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	result := db.Health(ctx)
+	if result.Status != health.Unhealthy {
+		t.Errorf("expected Unhealthy; got %s: %s", result.Status, result.Description)
+	}
+	if _, ok := result.Data["open_connections"]; !ok {
+		t.Errorf("expected pool statistics; got %v", result.Data)
+	}
+}
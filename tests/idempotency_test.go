@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-chi-api/internal/domain"
+	"go-chi-api/internal/problem"
+	"go-chi-api/internal/server"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Blocks creating users until released, so a request can be held in flight
+type blockingDatabase struct {
+	fakeDatabase
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (d *blockingDatabase) CreateUser(ctx context.Context, user *domain.User) error {
+	d.entered <- struct{}{}
+	<-d.release
+	return d.fakeDatabase.CreateUser(ctx, user)
+}
+
+func registerWithKey(t *testing.T, h http.Handler, key string, credentials map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	return registerWithKeyFrom(t, h, "192.0.2.1:1234", key, credentials)
+}
+
+func registerWithKeyFrom(t *testing.T, h http.Handler, remoteAddr string, key string, credentials map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(credentials)
+	if err != nil {
+		t.Fatalf("error marshaling request. Err: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1.0/auth/register", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	req.RemoteAddr = remoteAddr
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyKeyReplaysResponse(t *testing.T) {
+	h := newTestServer(t).Handler()
+	credentials := map[string]string{"username": "myusername123", "password": "superpassword1234"}
+
+	first := registerWithKey(t, h, "register-1", credentials)
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("expected first request to be handled; got status %d", first.Code)
+	}
+
+	retry := registerWithKey(t, h, `"register-1"`, credentials)
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected retry to replay 201; got status %d", retry.Code)
+	}
+	if retry.Header().Get("Location") != first.Header().Get("Location") {
+		t.Errorf("expected replayed Location %q; got %q", first.Header().Get("Location"), retry.Header().Get("Location"))
+	}
+
+	// Without the key the duplicate is handled again, and rejected
+	if rec := doJson(t, h, http.MethodPost, "/v1.0/auth/register", credentials); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected duplicate without a key to be rejected; got status %d", rec.Code)
+	}
+
+	credentials["password"] = "anotherpassword1234"
+	rec := registerWithKey(t, h, "register-1", credentials)
+	if p := decodeProblem(t, rec); p.Type != problem.IdempotencyKeyReused.URI {
+		t.Errorf("expected idempotency key reused problem; got %+v", p)
+	}
+
+	rec = registerWithKey(t, h, "", credentials)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected empty key to be rejected; got status %d", rec.Code)
+	}
+}
+
+func TestIdempotencyKeyIsScopedToAnonymousClient(t *testing.T) {
+	h := newTestServer(t).Handler()
+
+	first := registerWithKeyFrom(t, h, "192.0.2.1:1234", "register-1",
+		map[string]string{"username": "myusername123", "password": "superpassword1234"})
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected first client's request to be handled; got status %d", first.Code)
+	}
+
+	second := registerWithKeyFrom(t, h, "192.0.2.2:1234", "register-1",
+		map[string]string{"username": "otherusername123", "password": "anotherpassword1234"})
+	if second.Code != http.StatusCreated || second.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("expected second client's request to be handled; got status %d", second.Code)
+	}
+}
+
+func TestIdempotencyKeyInFlight(t *testing.T) {
+	db := &blockingDatabase{entered: make(chan struct{}), release: make(chan struct{})}
+	h := newTestServer(t, server.WithDatabase(db)).Handler()
+	credentials := map[string]string{"username": "myusername123", "password": "superpassword1234"}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- registerWithKey(t, h, "register-1", credentials)
+	}()
+	<-db.entered
+
+	rec := registerWithKey(t, h, "register-1", credentials)
+	if p := decodeProblem(t, rec); p.Type != problem.IdempotencyKeyInUse.URI || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected idempotency key in use problem with Retry-After; got %+v", p)
+	}
+
+	close(db.release)
+	if first := <-done; first.Code != http.StatusCreated {
+		t.Fatalf("expected first request to be handled; got status %d", first.Code)
+	}
+
+	if rec := registerWithKey(t, h, "register-1", credentials); rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("expected retry after completion to be replayed; got status %d", rec.Code)
+	}
+}
+
+func TestIdempotencyKeyReplaysCompressedResponse(t *testing.T) {
+	cfg := testConfig()
+	cfg.Compression.MinSize = 0
+	h := newTestServerWithConfig(t, cfg).Handler()
+
+	register := func(requestId string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1.0/auth/register", bytes.NewReader([]byte(`{"username": "myusername123", "password": "superpassword1234"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("Idempotency-Key", "register-1")
+		req.Header.Set("X-Request-Id", requestId)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// A duplicate registration is refused with a problem, giving a body to
+	// compress
+	credentials := map[string]string{"username": "myusername123", "password": "superpassword1234"}
+	if rec := doJson(t, h, http.MethodPost, "/v1.0/auth/register", credentials); rec.Code != http.StatusCreated {
+		t.Fatalf("expected registration to succeed; got status %d", rec.Code)
+	}
+
+	first := register("first-request")
+	retry := register("retried-request")
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected retry to be replayed; got status %d", retry.Code)
+	}
+
+	if encoding := retry.Header().Values("Content-Encoding"); len(encoding) != 1 || encoding[0] != "gzip" {
+		t.Fatalf("expected replay to be compressed once; got Content-Encoding %v", encoding)
+	}
+	if body := decompress(t, "gzip", retry.Body); body != decompress(t, "gzip", first.Body) {
+		t.Errorf("expected replayed body to match the first; got %s", body)
+	}
+	if id := retry.Header().Get("X-Request-Id"); id != "retried-request" {
+		t.Errorf("expected the retry's own request id; got %q", id)
+	}
+	if remaining := retry.Header().Get("RateLimit-Remaining"); remaining == first.Header().Get("RateLimit-Remaining") {
+		t.Errorf("expected the retry's own rate limit; got remaining %s for both", remaining)
+	}
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-chi-api/internal/logging"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLoggerAddsContextAndRedacts(t *testing.T) {
+	var out bytes.Buffer
+	logger := logging.New(&out, "json", slog.LevelInfo)
+
+	traceId, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	spanId, _ := trace.SpanIDFromHex("0102030405060708")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceId,
+		SpanID:  spanId,
+	}))
+	ctx = logging.NewContext(ctx, slog.String("request_id", "abc"))
+	logging.AddAttrs(ctx, slog.Int64("user_id", 42))
+
+	logger.DebugContext(ctx, "Filtered by level")
+	logger.InfoContext(ctx, "Logged in", "password", "hunter2", slog.Group("request", "Authorization", "Bearer x"))
+
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("expected exactly one JSON record. Err: %v, output: %s", err, out.String())
+	}
+
+	expected := map[string]any{
+		"msg":        "Logged in",
+		"request_id": "abc",
+		"user_id":    float64(42),
+		"trace_id":   traceId.String(),
+		"span_id":    spanId.String(),
+		"password":   "[REDACTED]",
+	}
+	for key, value := range expected {
+		if record[key] != value {
+			t.Errorf("expected %s to be %v; got %v", key, value, record[key])
+		}
+	}
+
+	request, _ := record["request"].(map[string]any)
+	if request["Authorization"] != "[REDACTED]" {
+		t.Errorf("expected nested Authorization to be redacted; got %v", request["Authorization"])
+	}
+}
+
+func TestGroupedLoggerKeepsContextAtTopLevel(t *testing.T) {
+	var out bytes.Buffer
+	logger := logging.New(&out, "json", slog.LevelInfo).With("service", "api").WithGroup("db").With("table", "users")
+
+	ctx := logging.NewContext(context.Background(), slog.String("request_id", "abc"))
+	logger.InfoContext(ctx, "Query failed", "token", "secret", "rows", 0)
+
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("expected exactly one JSON record. Err: %v, output: %s", err, out.String())
+	}
+
+	if record["request_id"] != "abc" || record["service"] != "api" {
+		t.Errorf("expected context and logger attributes at the top level; got %v", record)
+	}
+
+	db, _ := record["db"].(map[string]any)
+	expected := map[string]any{"table": "users", "token": "[REDACTED]", "rows": float64(0)}
+	for key, value := range expected {
+		if db[key] != value {
+			t.Errorf("expected db.%s to be %v; got %v", key, value, db[key])
+		}
+	}
+	if _, ok := db["request_id"]; ok {
+		t.Errorf("expected request_id outside the group; got %v", db)
+	}
+}
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"go-chi-api/internal/domain"
+	"go-chi-api/internal/problem"
+	"go-chi-api/internal/server"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Panics when looking up users, as a bug in a handler would
+type panickingDatabase struct {
+	fakeDatabase
+}
+
+func (d *panickingDatabase) GetUserByUsername(ctx context.Context, username string) (*domain.User, error) {
+	panic("lookup failed")
+}
+
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) problem.Problem {
+	t.Helper()
+	if contentType := rec.Header().Get("Content-Type"); contentType != problem.ContentType {
+		t.Fatalf("expected Content-Type %s; got %s", problem.ContentType, contentType)
+	}
+
+	var p problem.Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("error decoding problem. Err: %v", err)
+	}
+	if p.Status != rec.Code {
+		t.Errorf("expected problem status to match response status %d; got %d", rec.Code, p.Status)
+	}
+	if p.RequestId == "" {
+		t.Errorf("expected problem to identify the request")
+	}
+	return p
+}
+
+func TestErrorsAreProblems(t *testing.T) {
+	h := newTestServer(t).Handler()
+
+	rec := doJson(t, h, http.MethodPost, "/v1.0/auth/register", map[string]string{"username": "myusername123", "password": "short"})
+	p := decodeProblem(t, rec)
+	if p.Type != problem.ValidationFailed.URI || p.Instance != "/v1.0/auth/register" {
+		t.Errorf("expected validation problem for the request; got %+v", p)
+	}
+	expected := []problem.FieldError{{Pointer: "/password", Detail: "password must be at least 16 characters"}}
+	if len(p.Errors) != 1 || p.Errors[0] != expected[0] {
+		t.Errorf("expected field errors %+v; got %+v", expected, p.Errors)
+	}
+
+	rec = doJson(t, h, http.MethodPost, "/v1.0/auth/login", map[string]any{"username": 1})
+	if p := decodeProblem(t, rec); p.Type != problem.MalformedBody.URI || len(p.Errors) != 1 || p.Errors[0].Pointer != "/username" {
+		t.Errorf("expected malformed body problem pointing to /username; got %+v", p)
+	}
+
+	cases := map[string]problem.Type{
+		"/v1.0/auth/current/": problem.Unauthorized,
+		"/v1.0/missing":       problem.NotFound,
+	}
+	for path, expected := range cases {
+		rec := doJson(t, h, http.MethodGet, path, nil)
+		if p := decodeProblem(t, rec); p.Type != expected.URI {
+			t.Errorf("expected %s problem for %s; got %s", expected.Title, path, p.Type)
+		}
+	}
+
+	rec = doJson(t, h, http.MethodDelete, "/v1.0/auth/login", nil)
+	if p := decodeProblem(t, rec); p.Type != problem.MethodNotAllowed.URI {
+		t.Errorf("expected method not allowed problem; got %s", p.Type)
+	}
+}
+
+func TestPanicsAreRecoveredAsProblems(t *testing.T) {
+	h := newTestServer(t, server.WithDatabase(&panickingDatabase{})).Handler()
+
+	rec := doJson(t, h, http.MethodPost, "/v1.0/auth/login", map[string]string{"username": "myusername123", "password": "superpassword1234"})
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500; got %d", rec.Code)
+	}
+	if p := decodeProblem(t, rec); p.Type != problem.InternalServerError.URI || strings.Contains(p.Detail, "lookup failed") {
+		t.Errorf("expected internal server error problem not disclosing the panic; got %+v", p)
+	}
+}
+
+func TestProblemTypesAreDocumented(t *testing.T) {
+	docs, err := os.ReadFile("../docs/problems.md")
+	if err != nil {
+		t.Fatalf("error reading problem documentation. Err: %v", err)
+	}
+
+	// GitHub's anchors for the headings
+	anchors := make(map[string]bool)
+	punctuation := regexp.MustCompile(`[^a-z0-9 -]`)
+	for _, line := range strings.Split(string(docs), "\n") {
+		if heading, ok := strings.CutPrefix(line, "## "); ok {
+			anchor := punctuation.ReplaceAllString(strings.ToLower(heading), "")
+			anchors[strings.ReplaceAll(anchor, " ", "-")] = true
+		}
+	}
+
+	for _, problemType := range problem.Types() {
+		page, anchor, _ := strings.Cut(problemType.URI, "#")
+		if strings.HasSuffix(page, "docs/problems.md") && !anchors[anchor] {
+			t.Errorf("expected %s to be documented in docs/problems.md", problemType.Title)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"go-chi-api/internal/problem"
+	"go-chi-api/internal/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitAlgorithms(t *testing.T) {
+	start := time.Date(2024, time.January, 2, 3, 4, 0, 0, time.UTC)
+	limit := ratelimit.Limit{Requests: 3, Period: 3 * time.Second}
+
+	cases := []struct {
+		name      string
+		algorithm ratelimit.Algorithm
+		offsets   []time.Duration
+		allowed   []bool
+	}{
+		{
+			"token bucket refills one token a second",
+			ratelimit.TokenBucket,
+			[]time.Duration{0, 0, 0, 0, time.Second, time.Second},
+			[]bool{true, true, true, false, true, false},
+		},
+		{
+			"sliding window weights the previous window",
+			ratelimit.SlidingWindow,
+			[]time.Duration{0, 0, 0, 0, 3 * time.Second, 4 * time.Second, 5 * time.Second},
+			[]bool{true, true, true, false, false, true, true},
+		},
+	}
+	for _, c := range cases {
+		limiter := ratelimit.New(c.algorithm, ratelimit.NewMemoryStore(), limit)
+		for i, offset := range c.offsets {
+			result, err := limiter.Take(context.Background(), "key", start.Add(offset))
+			if err != nil {
+				t.Fatalf("error taking request. Err: %v", err)
+			}
+			if result.Allowed != c.allowed[i] {
+				t.Errorf("%s: expected request %d allowed to be %t", c.name, i, c.allowed[i])
+			}
+			if !result.Allowed && result.RetryAfter <= 0 {
+				t.Errorf("%s: expected request %d to say when to retry", c.name, i)
+			}
+		}
+	}
+}
+
+func TestAuthRoutesAreRateLimitedByIp(t *testing.T) {
+	h := newTestServer(t).Handler()
+	post := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1.0/auth/login", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := range 20 {
+		rec := post("192.0.2.1:1234")
+		if rec.Code == http.StatusTooManyRequests {
+			t.Fatalf("expected request %d to be allowed", i)
+		}
+		if i == 0 && (rec.Header().Get("RateLimit-Limit") != "20" || rec.Header().Get("RateLimit-Remaining") != "19") {
+			t.Errorf("expected RateLimit headers describing the limit; got %v", rec.Header())
+		}
+	}
+
+	rec := post("192.0.2.1:5678")
+	if p := decodeProblem(t, rec); p.Type != problem.TooManyRequests.URI {
+		t.Fatalf("expected too many requests problem; got %+v", p)
+	}
+	if retryAfter := rec.Header().Get("Retry-After"); retryAfter != "58" {
+		t.Errorf("expected Retry-After of 58 seconds; got %q", retryAfter)
+	}
+
+	if rec := post("192.0.2.2:1234"); rec.Code == http.StatusTooManyRequests {
+		t.Errorf("expected another client to be allowed")
+	}
+}
+
+func TestRateLimitIgnoresForwardingHeadersFromUntrustedPeers(t *testing.T) {
+	h := newTestServer(t).Handler()
+	post := func(remoteAddr string, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1.0/auth/login", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := range 20 {
+		post("192.0.2.1:1234", fmt.Sprintf("198.51.100.%d", i))
+	}
+	if rec := post("192.0.2.1:1234", "198.51.100.99"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a forged X-Forwarded-For to be ignored; got status %d", rec.Code)
+	}
+
+	// Through a trusted proxy, the client is the last address it did not add.
+	// The first is made up by the client, so does not identify it
+	for i := range 20 {
+		post("127.0.0.1:1234", fmt.Sprintf("198.51.100.%d, 203.0.113.7", i))
+	}
+	if rec := post("127.0.0.1:1234", "198.51.100.99, 203.0.113.7"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the client forwarded by a trusted proxy to be limited; got status %d", rec.Code)
+	}
+	if rec := post("127.0.0.1:1234", "203.0.113.8"); rec.Code == http.StatusTooManyRequests {
+		t.Errorf("expected another client forwarded by a trusted proxy to be allowed")
+	}
+}
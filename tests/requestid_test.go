@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"context"
+	"go-chi-api/internal/requestid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestIdIsEchoedOrGenerated(t *testing.T) {
+	h := newTestServer(t).Handler()
+	get := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1.0/nothing", nil)
+		if id != "" {
+			req.Header.Set(requestid.Header, id)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("client-id.123")
+	if id := rec.Header().Get(requestid.Header); id != "client-id.123" {
+		t.Errorf("expected valid request id to be echoed; got %q", id)
+	}
+	if p := decodeProblem(t, rec); p.RequestId != "client-id.123" {
+		t.Errorf("expected problem to carry the request id; got %q", p.RequestId)
+	}
+
+	for _, invalid := range []string{"", "has space", "line\nbreak", strings.Repeat("a", 129)} {
+		id := get(invalid).Header().Get(requestid.Header)
+		if id == "" || id == invalid || !requestid.Valid(id) {
+			t.Errorf("expected a new request id instead of %q; got %q", invalid, id)
+		}
+	}
+}
+
+func TestRequestIdDerivedFromTrace(t *testing.T) {
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	if id := requestid.New(ctx); id != "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7" {
+		t.Errorf("expected id made of the trace and span ids; got %q", id)
+	}
+}
+
+func TestRequestIdIsPropagated(t *testing.T) {
+	received := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(requestid.Header)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: &requestid.Transport{}}
+	req, err := http.NewRequestWithContext(requestid.NewContext(context.Background(), "client-id.123"), http.MethodGet, upstream.URL, nil)
+	if err != nil {
+		t.Fatalf("error creating request. Err: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("error sending request. Err: %v", err)
+	}
+	resp.Body.Close()
+
+	if id := <-received; id != "client-id.123" {
+		t.Errorf("expected request id to be propagated; got %q", id)
+	}
+	if req.Header.Get(requestid.Header) != "" {
+		t.Errorf("expected the original request to be left unchanged")
+	}
+}
@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-chi-api/internal/authentication"
+	"go-chi-api/internal/config"
+	"go-chi-api/internal/database"
+	"go-chi-api/internal/domain"
+	"go-chi-api/internal/health"
+	"go-chi-api/internal/idempotency"
+	"go-chi-api/internal/ratelimit"
+	"go-chi-api/internal/server"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeDatabase struct {
+	mu    sync.Mutex
+	users []*domain.User
+
+	// Returned by Health when set, instead of a healthy result
+	health *health.Result
+
+	idempotency *idempotency.MemoryStore
+}
+
+func (d *fakeDatabase) Health(ctx context.Context) health.Result {
+	if d.health != nil {
+		return *d.health
+	}
+	return health.Result{Status: health.Healthy, Description: database.HealthyMessage}
+}
+
+func (d *fakeDatabase) GetUserById(ctx context.Context, id int64) (*domain.User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, user := range d.users {
+		if user.Id == id {
+			u := *user
+			return &u, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *fakeDatabase) GetUserByUsername(ctx context.Context, username string) (*domain.User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, user := range d.users {
+		if user.Username == username {
+			u := *user
+			return &u, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *fakeDatabase) CreateUser(ctx context.Context, user *domain.User) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, existing := range d.users {
+		if existing.Username == user.Username {
+			return sql.ErrNoRows
+		}
+	}
+	user.Id = int64(len(d.users) + 1)
+	u := *user
+	d.users = append(d.users, &u)
+	return nil
+}
+
+func (d *fakeDatabase) IdempotencyStore() idempotency.Store {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.idempotency == nil {
+		d.idempotency = idempotency.NewMemoryStore()
+	}
+	return d.idempotency
+}
+
+func (d *fakeDatabase) RateLimitStore() ratelimit.Store {
+	return ratelimit.NewMemoryStore()
+}
+
+func (d *fakeDatabase) Close() error {
+	return nil
+}
+
+type fakeOtel struct{}
+
+func (o fakeOtel) Shutdown(context.Context) error {
+	return nil
+}
+
+func (o fakeOtel) MetricsHandler() http.Handler {
+	return nil
+}
+
+// Returns the configuration test servers use unless a test changes it
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Server.Port = 3000
+	cfg.Authentication.JwtSecret = "test-secret-that-is-long-enough"
+	return cfg
+}
+
+func newTestServer(t *testing.T, opts ...server.Option) *server.Server {
+	t.Helper()
+	return newTestServerWithConfig(t, testConfig(), opts...)
+}
+
+func newTestServerWithConfig(t *testing.T, cfg *config.Config, opts ...server.Option) *server.Server {
+	t.Helper()
+	return newTestServerWithStore(t, config.NewStore(cfg, nil), opts...)
+}
+
+func newTestServerWithStore(t *testing.T, store *config.Store, opts ...server.Option) *server.Server {
+	t.Helper()
+	auth, err := authentication.New(store.Load().Authentication)
+	if err != nil {
+		t.Fatalf("error creating authentication service. Err: %v", err)
+	}
+
+	now := time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+	defaults := []server.Option{
+		server.WithDatabase(&fakeDatabase{}),
+		server.WithAuthentication(auth),
+		server.WithOtel(fakeOtel{}),
+		server.WithClock(func() time.Time { return now }),
+		server.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}
+
+	s, err := server.NewServer(context.Background(), store, "test", append(defaults, opts...)...)
+	if err != nil {
+		t.Fatalf("error creating server. Err: %v", err)
+	}
+	return s
+}
+
+func doJson(t *testing.T, h http.Handler, method string, path string, body any, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("error marshaling request. Err: %v", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNewServerRequiresJwtSecret(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.Port = 3000
+	_, err := server.NewServer(
+		context.Background(),
+		config.NewStore(cfg, nil),
+		"test",
+		server.WithDatabase(&fakeDatabase{}),
+		server.WithOtel(fakeOtel{}),
+	)
+	if !errors.Is(err, authentication.ErrMissingJwtSecret) {
+		t.Fatalf("expected ErrMissingJwtSecret; got %v", err)
+	}
+}
+
+func TestRegisterLoginAndGetCurrentUser(t *testing.T) {
+	h := newTestServer(t).Handler()
+	credentials := map[string]string{
+		"username": "myusername123",
+		"password": "superpassword1234",
+	}
+
+	rec := doJson(t, h, http.MethodPost, "/v1.0/auth/register", credentials)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected register status 201; got %d", rec.Code)
+	}
+
+	rec = doJson(t, h, http.MethodPost, "/v1.0/auth/register", credentials)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected duplicate register status 400; got %d", rec.Code)
+	}
+
+	rec = doJson(t, h, http.MethodGet, "/v1.0/auth/current/", nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected unauthenticated status 401; got %d", rec.Code)
+	}
+
+	rec = doJson(t, h, http.MethodPost, "/v1.0/auth/login", credentials)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected login status 204; got %d", rec.Code)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected one authentication cookie; got %d", len(cookies))
+	}
+
+	rec = doJson(t, h, http.MethodGet, "/v1.0/auth/current/", nil, cookies[0])
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected current user status 200; got %d", rec.Code)
+	}
+
+	var response map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("error decoding response. Err: %v", err)
+	}
+	if response["username"] != credentials["username"] {
+		t.Errorf("expected username %s; got %v", credentials["username"], response["username"])
+	}
+	if response["created_at"] != "2024-01-02T03:04:05Z" {
+		t.Errorf("expected created_at from injected clock; got %v", response["created_at"])
+	}
+}
@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	telemetryOnce sync.Once
+	spanRecorder  *tracetest.SpanRecorder
+	metricReader  *metric.ManualReader
+)
+
+// Installs global providers recording to memory. The global providers only
+// delegate to the first providers set, so they are shared by every test
+func installTestTelemetry() (*tracetest.SpanRecorder, *metric.ManualReader) {
+	telemetryOnce.Do(func() {
+		spanRecorder = tracetest.NewSpanRecorder()
+		metricReader = metric.NewManualReader()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+		meterProvider := metric.NewMeterProvider(metric.WithReader(metricReader))
+		otel.SetMeterProvider(meterProvider)
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+
+		// Started by otel.New, which tests do not use as it exports telemetry
+		if err := runtime.Start(runtime.WithMeterProvider(meterProvider)); err != nil {
+			panic(err)
+		}
+	})
+
+	return spanRecorder, metricReader
+}
+
+func findMetric(t *testing.T, reader *metric.ManualReader, name string) metricdata.Metrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+
+	t.Fatalf("metric %s was not recorded", name)
+	return metricdata.Metrics{}
+}
+
+func TestHTTPMiddlewareRecordsRouteSpansAndMetrics(t *testing.T) {
+	recorder, reader := installTestTelemetry()
+	h := newTestServer(t).Handler()
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/v1.0/auth/current/", nil)
+	req.Header.Set("traceparent", parent)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("current user: got %d", rr.Code)
+	}
+
+	var span sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if s.Name() == "GET /v1.0/auth/current" {
+			span = s
+		}
+	}
+	if span == nil {
+		t.Fatal("no span named after the route pattern was recorded")
+	}
+
+	if got := span.Parent().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("span did not continue the propagated trace, parent trace id %s", got)
+	}
+	if span.Status().Code == codes.Error {
+		t.Error("4xx span marked as an error")
+	}
+
+	attrs := attribute.NewSet(span.Attributes()...)
+	if address, _ := attrs.Value(attribute.Key("client.address")); address.AsString() != "192.0.2.1" {
+		t.Errorf("expected client.address 192.0.2.1; got %q", address.AsString())
+	}
+	if port, _ := attrs.Value(attribute.Key("client.port")); port.AsInt64() != 1234 {
+		t.Errorf("expected client.port 1234; got %d", port.AsInt64())
+	}
+
+	duration := findMetric(t, reader, "http.server.request.duration")
+	histogram, ok := duration.Data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("http.server.request.duration is %T", duration.Data)
+	}
+
+	var found bool
+	for _, point := range histogram.DataPoints {
+		route, _ := point.Attributes.Value(attribute.Key("http.route"))
+		status, _ := point.Attributes.Value(attribute.Key("http.response.status_code"))
+		if route.AsString() == "/v1.0/auth/current" && status.AsInt64() == http.StatusUnauthorized {
+			found = point.Count > 0
+		}
+	}
+	if !found {
+		t.Errorf("no duration recorded for the route, got %+v", histogram.DataPoints)
+	}
+}
+
+// Returns the value of the counter's data point having exactly attrs
+func counterValue(t *testing.T, reader *metric.ManualReader, name string, attrs ...attribute.KeyValue) int64 {
+	t.Helper()
+	sum, ok := findMetric(t, reader, name).Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("%s is not an integer counter", name)
+	}
+
+	want := attribute.NewSet(attrs...)
+	for _, point := range sum.DataPoints {
+		if point.Attributes.Equals(&want) {
+			return point.Value
+		}
+	}
+
+	return 0
+}
+
+func TestAuthenticationMetrics(t *testing.T) {
+	_, reader := installTestTelemetry()
+	h := newTestServer(t).Handler()
+
+	credentials := map[string]string{"username": "metrics", "password": "correct horse battery"}
+	if rr := doJson(t, h, http.MethodPost, "/v1.0/auth/register", credentials); rr.Code != http.StatusCreated {
+		t.Fatalf("register: got %d", rr.Code)
+	}
+
+	badPassword := map[string]string{"username": "metrics", "password": "incorrect horse battery"}
+	unknownUser := map[string]string{"username": "nobody", "password": "correct horse battery"}
+	for _, body := range []map[string]string{badPassword, unknownUser} {
+		if rr := doJson(t, h, http.MethodPost, "/v1.0/auth/login", body); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("login: got %d", rr.Code)
+		}
+	}
+
+	if rr := doJson(t, h, http.MethodPost, "/v1.0/auth/login", credentials); rr.Code != http.StatusNoContent {
+		t.Fatalf("login: got %d", rr.Code)
+	}
+
+	malformed := &http.Cookie{Name: "token", Value: "not-a-jwt"}
+	if rr := doJson(t, h, http.MethodGet, "/v1.0/auth/current/", nil, malformed); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("current user: got %d", rr.Code)
+	}
+
+	result := func(value string) attribute.KeyValue { return attribute.String("result", value) }
+	reason := func(value string) attribute.KeyValue { return attribute.String("reason", value) }
+	counts := []struct {
+		name  string
+		attrs []attribute.KeyValue
+	}{
+		{"auth.registrations", []attribute.KeyValue{result("success")}},
+		{"auth.logins", []attribute.KeyValue{result("success")}},
+		{"auth.logins", []attribute.KeyValue{result("failure"), reason("bad_password")}},
+		{"auth.logins", []attribute.KeyValue{result("failure"), reason("unknown_user")}},
+		{"auth.token.validation_failures", []attribute.KeyValue{reason("malformed")}},
+	}
+	for _, count := range counts {
+		if got := counterValue(t, reader, count.name, count.attrs...); got < 1 {
+			t.Errorf("%s %v: got %d", count.name, count.attrs, got)
+		}
+	}
+
+	findMetric(t, reader, "auth.password.hash.duration")
+}
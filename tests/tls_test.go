@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"go-chi-api/internal/config"
+	"go-chi-api/internal/devcert"
+	"go-chi-api/internal/server"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// Writes a new development certificate to dir, returning the certificate and
+// the paths of its files
+func writeDevCert(t *testing.T, dir string, modTime time.Time) (*x509.Certificate, string, string) {
+	t.Helper()
+	certPEM, keyPEM, err := devcert.Generate([]string{"localhost", "127.0.0.1"}, time.Now(), time.Hour)
+	if err != nil {
+		t.Fatalf("error generating certificate. Err: %v", err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	for path, data := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("error writing %s. Err: %v", path, err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("error setting modification time of %s. Err: %v", path, err)
+		}
+	}
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("error parsing certificate. Err: %v", err)
+	}
+	return pair.Leaf, certFile, keyFile
+}
+
+func startTLSServer(t *testing.T, cfg *config.Config) *httptest.Server {
+	t.Helper()
+	s := newTestServerWithConfig(t, cfg)
+	ts := httptest.NewUnstartedServer(s.Handler())
+	ts.TLS = s.TLSConfig()
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func tlsClient(roots *x509.CertPool, certificates ...tls.Certificate) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		ForceAttemptHTTP2: true,
+		TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			ServerName:   "localhost",
+			Certificates: certificates,
+		},
+	}}
+}
+
+func TestTLSServesHTTP2AndReloadsCertificates(t *testing.T) {
+	dir := t.TempDir()
+	first, certFile, keyFile := writeDevCert(t, dir, time.Now().Add(-time.Hour))
+
+	cfg := testConfig()
+	cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile = certFile, keyFile
+	cfg.Server.TLS.ReloadInterval = 0
+	ts := startTLSServer(t, cfg)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(first)
+	resp, err := tlsClient(roots).Get(ts.URL + "/v1.0/health/live")
+	if err != nil {
+		t.Fatalf("error making request. Err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 || !resp.TLS.PeerCertificates[0].Equal(first) {
+		t.Fatalf("expected HTTP/2 with the configured certificate; got %s", resp.Proto)
+	}
+
+	second, _, _ := writeDevCert(t, dir, time.Now())
+	roots.AddCert(second)
+	resp, err = tlsClient(roots).Get(ts.URL + "/v1.0/health/live")
+	if err != nil {
+		t.Fatalf("error making request. Err: %v", err)
+	}
+	resp.Body.Close()
+	if !resp.TLS.PeerCertificates[0].Equal(second) {
+		t.Errorf("expected the replaced certificate to be served")
+	}
+}
+
+func TestTLSVerifiesClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, certFile, keyFile := writeDevCert(t, dir, time.Now())
+
+	clientDir := t.TempDir()
+	_, clientCertFile, clientKeyFile := writeDevCert(t, clientDir, time.Now())
+	clientPair, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	if err != nil {
+		t.Fatalf("error loading client certificate. Err: %v", err)
+	}
+
+	cfg := testConfig()
+	cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile = certFile, keyFile
+	cfg.Server.TLS.ClientAuth = "require_and_verify"
+	cfg.Server.TLS.ClientCAFile = clientCertFile
+	ts := startTLSServer(t, cfg)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert)
+	if resp, err := tlsClient(roots).Get(ts.URL + "/v1.0/health/live"); err == nil {
+		resp.Body.Close()
+		t.Errorf("expected a client without a certificate to be refused")
+	}
+
+	resp, err := tlsClient(roots, clientPair).Get(ts.URL + "/v1.0/health/live")
+	if err != nil {
+		t.Fatalf("expected a client with a trusted certificate to be accepted. Err: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestTLSConfigurationIsValidated(t *testing.T) {
+	_, certFile, keyFile := writeDevCert(t, t.TempDir(), time.Now())
+	newServer := func(change func(*config.TLS)) error {
+		cfg := testConfig()
+		cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile = certFile, keyFile
+		change(&cfg.Server.TLS)
+		_, err := server.NewServer(context.Background(), config.NewStore(cfg, nil), "test", server.WithDatabase(&fakeDatabase{}), server.WithOtel(fakeOtel{}))
+		return err
+	}
+
+	if err := newServer(func(c *config.TLS) { c.CipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"} }); err == nil {
+		t.Errorf("expected an insecure cipher suite to be rejected")
+	}
+	if err := newServer(func(c *config.TLS) { c.CipherSuites = []string{"TLS_AES_128_GCM_SHA256"} }); err == nil {
+		t.Errorf("expected a TLS 1.3 cipher suite to be rejected")
+	}
+	if err := newServer(func(c *config.TLS) { c.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"} }); err != nil {
+		t.Errorf("expected a TLS 1.2 cipher suite to be accepted; got %v", err)
+	}
+	if err := newServer(func(c *config.TLS) { c.ClientAuth = "verify_if_given" }); !errors.Is(err, server.ErrClientCAFileRequired) {
+		t.Errorf("expected ErrClientCAFileRequired; got %v", err)
+	}
+	if err := newServer(func(c *config.TLS) { c.KeyFile = "" }); !errors.Is(err, server.ErrIncompleteKeyPair) {
+		t.Errorf("expected ErrIncompleteKeyPair; got %v", err)
+	}
+	if err := newServer(func(c *config.TLS) { c.MinVersion = "1.3" }); err != nil {
+		t.Errorf("expected a valid configuration to be accepted; got %v", err)
+	}
+}
+
+func TestH2C(t *testing.T) {
+	cfg := testConfig()
+	cfg.Server.H2C = true
+	ts := httptest.NewServer(newTestServerWithConfig(t, cfg).Handler())
+	defer ts.Close()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network string, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	resp, err := client.Get(ts.URL + "/v1.0/health/live")
+	if err != nil {
+		t.Fatalf("error making request. Err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK {
+		t.Errorf("expected HTTP/2 without TLS; got %s with status %d", resp.Proto, resp.StatusCode)
+	}
+}
+
+func TestTLSConfigRequiresBothFiles(t *testing.T) {
+	setRequiredConfigEnv(t)
+	_, certFile, _ := writeDevCert(t, t.TempDir(), time.Now())
+	t.Setenv("TLS_CERT_FILE", certFile)
+
+	_, err := config.Load(nil)
+	if !errors.Is(err, config.ErrInvalidConfig) || !strings.Contains(err.Error(), "$TLS_KEY_FILE") {
+		t.Errorf("expected a certificate without a key to be rejected; got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidationMessagesFollowAcceptLanguage(t *testing.T) {
+	h := newTestServer(t).Handler()
+	body, _ := json.Marshal(map[string]string{"username": "my username", "password": "short"})
+
+	cases := map[string]struct {
+		contentLanguage string
+		username        string
+		password        string
+	}{
+		"": {
+			"en",
+			"username may only contain letters, numbers, '.', '_' and '-'",
+			"password must be at least 16 characters",
+		},
+		"de-DE, fr-CA;q=0.9, en;q=0.8": {
+			"fr",
+			"username ne peut contenir que des lettres, des chiffres, '.', '_' et '-'",
+			"password doit faire une taille minimum de 16 caractères",
+		},
+		"en;q=0.5, es": {
+			"es",
+			"username solo puede contener letras, números, '.', '_' y '-'",
+			"password debe tener al menos 16 caracteres de longitud",
+		},
+	}
+
+	for acceptLanguage, expected := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/v1.0/auth/register", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", acceptLanguage)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if contentLanguage := rec.Header().Get("Content-Language"); contentLanguage != expected.contentLanguage {
+			t.Errorf("expected Content-Language %s for %q; got %s", expected.contentLanguage, acceptLanguage, contentLanguage)
+		}
+
+		details := make(map[string]string)
+		for _, fieldError := range decodeProblem(t, rec).Errors {
+			details[fieldError.Pointer] = fieldError.Detail
+		}
+		want := map[string]string{"/username": expected.username, "/password": expected.password}
+		for pointer, detail := range want {
+			if details[pointer] != detail {
+				t.Errorf("expected %s error %q for %q; got %q", pointer, detail, acceptLanguage, details[pointer])
+			}
+		}
+	}
+}